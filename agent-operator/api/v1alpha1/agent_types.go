@@ -18,7 +18,9 @@ package v1alpha1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -40,6 +42,217 @@ type EnvironmentConfig struct {
 	Env []corev1.EnvVar `json:"env,omitempty"`
 }
 
+// MessagingSpec configures how an agent consumes its inbox stream and how
+// many messages it accepts
+type MessagingSpec struct {
+	// ClaimIdleSeconds is how long an inbox message may stay unacknowledged with
+	// a consumer before the agent reclaims it, e.g. after its pod was restarted.
+	// Defaults to 300.
+	// +optional
+	// +kubebuilder:default:=300
+	// +kubebuilder:validation:Minimum:=1
+	ClaimIdleSeconds int64 `json:"claimIdleSeconds,omitempty"`
+
+	// MaxDeliveries is how many times an inbox message is delivered without
+	// being acknowledged before it is moved to the dead-letter stream of the
+	// agent type. Defaults to 5.
+	// +optional
+	// +kubebuilder:default:=5
+	// +kubebuilder:validation:Minimum:=1
+	MaxDeliveries int64 `json:"maxDeliveries,omitempty"`
+
+	// MaxInboxLength caps the inbox stream, which otherwise keeps every
+	// message ever sent to the agent. With the MaxLen trim policy the inbox
+	// is trimmed to about this many entries whenever a message is added.
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	MaxInboxLength int64 `json:"maxInboxLength,omitempty"`
+
+	// InboxTrimPolicy selects how the inbox is trimmed when a message is
+	// added: MaxLen keeps about MaxInboxLength entries, MinID drops entries
+	// older than InboxRetentionSeconds. Defaults to MaxLen.
+	// +optional
+	InboxTrimPolicy InboxTrimPolicy `json:"inboxTrimPolicy,omitempty"`
+
+	// InboxRetentionSeconds is the age at which the MinID trim policy drops
+	// inbox entries. Defaults to 86400.
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	InboxRetentionSeconds int64 `json:"inboxRetentionSeconds,omitempty"`
+
+	// MaxBacklog is how many unconsumed messages, i.e. not yet delivered or
+	// not yet acknowledged, the inbox may hold before new messages are
+	// refused with 503 Service Unavailable. Defaults to MaxInboxLength, so
+	// trimming never drops messages the agent has not seen.
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	MaxBacklog int64 `json:"maxBacklog,omitempty"`
+
+	// RateLimit limits how many messages the operator API accepts for the
+	// agent, across all callers. Defaults to the operator's
+	// --api-agent-rate-limit.
+	// +optional
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+}
+
+// InboxTrimPolicy is how an agent's inbox stream is trimmed
+// +kubebuilder:validation:Enum=MaxLen;MinID
+type InboxTrimPolicy string
+
+const (
+	// InboxTrimMaxLen trims the inbox to approximately MaxInboxLength entries
+	InboxTrimMaxLen InboxTrimPolicy = "MaxLen"
+	// InboxTrimMinID drops inbox entries older than InboxRetentionSeconds
+	InboxTrimMinID InboxTrimPolicy = "MinID"
+)
+
+// RateLimit is a token bucket that refills at MessagesPerMinute and holds up
+// to Burst messages
+type RateLimit struct {
+	// MessagesPerMinute is the sustained rate of messages
+	// +kubebuilder:validation:Minimum:=1
+	MessagesPerMinute int32 `json:"messagesPerMinute"`
+
+	// Burst is how many messages may be sent at once. Defaults to
+	// MessagesPerMinute.
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	Burst int32 `json:"burst,omitempty"`
+}
+
+// WorkloadKind selects what a long-running agent runs as
+// +kubebuilder:validation:Enum=Pod;Deployment;StatefulSet
+type WorkloadKind string
+
+const (
+	// WorkloadKindPod runs the agent as a bare pod the operator restarts
+	// itself, with the backoff of the agent's restart policy
+	WorkloadKindPod WorkloadKind = "Pod"
+	// WorkloadKindDeployment runs the agent as a Deployment of one replica,
+	// which survives node drains and rolls out spec changes
+	WorkloadKindDeployment WorkloadKind = "Deployment"
+	// WorkloadKindStatefulSet runs the agent as a StatefulSet of one replica
+	// with a stable pod name and network identity, and optionally a
+	// persistent volume
+	WorkloadKindStatefulSet WorkloadKind = "StatefulSet"
+)
+
+// UpdateStrategyType selects how the pod of an agent is replaced when its
+// spec changes
+// +kubebuilder:validation:Enum=Recreate;RollingUpdate
+type UpdateStrategyType string
+
+const (
+	// UpdateStrategyRecreate deletes the agent's pod before its replacement
+	// is created
+	UpdateStrategyRecreate UpdateStrategyType = "Recreate"
+	// UpdateStrategyRollingUpdate creates the replacement pod first unless
+	// MaxUnavailable allows the agent's pod to be unavailable
+	UpdateStrategyRollingUpdate UpdateStrategyType = "RollingUpdate"
+)
+
+// UpdateStrategy configures how changes to the spec of an agent, such as a
+// new image or environment, are rolled out to its pod
+type UpdateStrategy struct {
+	// Type is Recreate or RollingUpdate. Defaults to RollingUpdate.
+	// +optional
+	// +kubebuilder:default:=RollingUpdate
+	Type UpdateStrategyType `json:"type,omitempty"`
+
+	// MaxUnavailable is how many of the agent's pods may be unavailable
+	// during a rolling update. With the default of 0 the replacement pod has
+	// to become ready before the outdated one is deleted. StatefulSets always
+	// replace their pod in place, keeping its identity.
+	// +optional
+	// +kubebuilder:default:=0
+	// +kubebuilder:validation:Minimum:=0
+	// +kubebuilder:validation:Maximum:=1
+	MaxUnavailable *int32 `json:"maxUnavailable,omitempty"`
+}
+
+// JobSpec configures the Job a runOnce agent runs as
+type JobSpec struct {
+	// BackoffLimit is how many times a failed pod is retried before the Job
+	// fails. Defaults to 0, so a failed run is not retried.
+	// +optional
+	// +kubebuilder:default:=0
+	// +kubebuilder:validation:Minimum:=0
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+
+	// ActiveDeadlineSeconds limits how long the Job may run before it is
+	// failed and its pods are stopped
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+
+	// TTLSecondsAfterFinished is how long the finished Job and its pods are
+	// kept before they are deleted. The agent keeps its final status. By
+	// default they are kept until the agent is deleted.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+
+	// Parallelism is how many pods of the Job run at once, to fan a batch
+	// out. Defaults to 1.
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	Parallelism *int32 `json:"parallelism,omitempty"`
+
+	// Completions is how many pods have to succeed for the Job to complete.
+	// Defaults to 1.
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	Completions *int32 `json:"completions,omitempty"`
+}
+
+// StorageSpec describes the persistent volume of an agent run as a
+// StatefulSet
+type StorageSpec struct {
+	// Size is the requested size of the volume
+	// +kubebuilder:validation:Required
+	Size resource.Quantity `json:"size"`
+
+	// StorageClassName is the storage class of the volume. Defaults to the
+	// cluster's default storage class.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// MountPath is where the volume is mounted in the agent container.
+	// Defaults to /data.
+	// +optional
+	// +kubebuilder:default:="/data"
+	MountPath string `json:"mountPath,omitempty"`
+}
+
+// RestartPolicy configures how long the operator waits before restarting
+// the failed pod of a long-running agent. The delay starts at InitialDelay
+// and grows by Multiplier with each consecutive restart up to MaxDelay, with
+// some jitter so agents failing together do not restart together.
+type RestartPolicy struct {
+	// InitialDelay is the delay before the first restart. Defaults to 5s.
+	// +optional
+	// +kubebuilder:default:="5s"
+	InitialDelay *metav1.Duration `json:"initialDelay,omitempty"`
+
+	// MaxDelay caps the delay between restarts. Defaults to 5m.
+	// +optional
+	// +kubebuilder:default:="5m"
+	MaxDelay *metav1.Duration `json:"maxDelay,omitempty"`
+
+	// Multiplier is the factor the delay grows by with each consecutive
+	// restart, as a decimal string. Defaults to "2".
+	// +optional
+	// +kubebuilder:default:="2"
+	// +kubebuilder:validation:Pattern:=`^[0-9]+(\.[0-9]+)?$`
+	Multiplier string `json:"multiplier,omitempty"`
+
+	// ResetAfter is how long the pod has to stay running and ready before
+	// the delay drops back to InitialDelay. Defaults to 10m.
+	// +optional
+	// +kubebuilder:default:="10m"
+	ResetAfter *metav1.Duration `json:"resetAfter,omitempty"`
+}
+
 // AgentSpec defines the desired state of Agent
 type AgentSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
@@ -58,7 +271,7 @@ type AgentSpec struct {
 	Env []corev1.EnvVar `json:"env,omitempty"`
 
 	// RunOnce indicates if the agent should run to completion (one-shot) or run continuously.
-	// Defaults to false (long-running).
+	// Run-once agents run as a Job, configured by Job. Defaults to false (long-running).
 	// +optional
 	// +kubebuilder:default:=false
 	RunOnce bool `json:"runOnce,omitempty"`
@@ -71,6 +284,34 @@ type AgentSpec struct {
 	// +kubebuilder:validation:Minimum:=-1
 	MaxRestarts int `json:"maxRestarts,omitempty"`
 
+	// RestartPolicy configures the backoff between restarts of a failing pod
+	// for long-running agents. Ignored if runOnce is true or the agent is not
+	// run as a bare Pod, in which case the kubelet restarts its containers.
+	// +optional
+	RestartPolicy *RestartPolicy `json:"restartPolicy,omitempty"`
+
+	// WorkloadKind selects whether a long-running agent runs as a bare Pod,
+	// a Deployment or a StatefulSet. Defaults to Pod. Ignored if runOnce is
+	// true.
+	// +optional
+	// +kubebuilder:default:=Pod
+	WorkloadKind WorkloadKind `json:"workloadKind,omitempty"`
+
+	// UpdateStrategy configures how spec changes are rolled out to the pod
+	// of a long-running agent
+	// +optional
+	UpdateStrategy *UpdateStrategy `json:"updateStrategy,omitempty"`
+
+	// Job configures the Job a runOnce agent runs as. Ignored if runOnce is
+	// false. The Job's pod template cannot change once it has started, so
+	// spec changes do not affect a run in progress.
+	// +optional
+	Job *JobSpec `json:"job,omitempty"`
+
+	// Storage adds a persistent volume to an agent run as a StatefulSet
+	// +optional
+	Storage *StorageSpec `json:"storage,omitempty"`
+
 	// TTL defines the maximum time (in seconds) that an agent can be inactive before being automatically deleted.
 	// A value of 0 (default) means no TTL (agent is not ephemeral).
 	// +optional
@@ -82,11 +323,16 @@ type AgentSpec struct {
 	// +optional
 	LastActivityTime *metav1.Time `json:"lastActivityTime,omitempty"`
 
-	// InputSchemaRef is (future) Input schema
+	// InputSchemaRef references the JSON Schema that message payloads sent
+	// through the operator API must match. It names a ConfigMap in the
+	// agent's namespace, optionally followed by a slash and the key holding
+	// the schema, which defaults to schema.json (e.g. "hello-schemas/input.json").
 	// +optional
 	InputSchemaRef string `json:"inputSchemaRef,omitempty"`
 
-	// OutputSchemaRef is (future) Output schema
+	// OutputSchemaRef references the JSON Schema the agent's replies must
+	// match before the operator API returns them, in the same form as
+	// InputSchemaRef
 	// +optional
 	OutputSchemaRef string `json:"outputSchemaRef,omitempty"`
 
@@ -97,6 +343,10 @@ type AgentSpec struct {
 	// ServiceAccountName is the name of the service account to use
 	// +optional
 	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Messaging configures how the agent consumes its inbox
+	// +optional
+	Messaging *MessagingSpec `json:"messaging,omitempty"`
 }
 
 // AgentStatus defines the observed state of Agent
@@ -116,12 +366,96 @@ type AgentStatus struct {
 	// Only relevant for long-running agents (runOnce=false).
 	// +optional
 	RestartCount int `json:"restartCount,omitempty"`
+
+	// ConsecutiveRestarts counts the restarts since the pod last stayed
+	// healthy for the restart policy's ResetAfter; the restart delay grows
+	// with it
+	// +optional
+	ConsecutiveRestarts int `json:"consecutiveRestarts,omitempty"`
+
+	// NextRestartTime is when the operator restarts the agent's failed pod
+	// +optional
+	NextRestartTime *metav1.Time `json:"nextRestartTime,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was last
+	// computed from
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the state of the agent, see the Condition* types
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +patchStrategy=merge
+	// +patchMergeKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// PodName is the name of the agent's pod
+	// +optional
+	PodName string `json:"podName,omitempty"`
+
+	// StartTime is when the agent's current pod was started by the kubelet
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the Job of a runOnce agent completed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// JobUID is the UID of the Job a runOnce agent was started as. Once it
+	// is set the agent is not run again, even after the Job is deleted.
+	// +optional
+	JobUID types.UID `json:"jobUID,omitempty"`
+
+	// SucceededPods counts the pods of the Job of a runOnce agent that
+	// succeeded
+	// +optional
+	SucceededPods int32 `json:"succeededPods,omitempty"`
+
+	// FailedPods counts the pods of the Job of a runOnce agent that failed
+	// +optional
+	FailedPods int32 `json:"failedPods,omitempty"`
+
+	// ExitCode is the exit code of the agent container of the pod of a
+	// runOnce agent that terminated last
+	// +optional
+	ExitCode *int32 `json:"exitCode,omitempty"`
+
+	// TerminationMessage is the termination message of that container, or
+	// the end of its log if it failed without writing one
+	// +optional
+	TerminationMessage string `json:"terminationMessage,omitempty"`
+
+	// LastFailureReason is the reason the agent's pod last failed, e.g.
+	// OOMKilled or Error
+	// +optional
+	LastFailureReason string `json:"lastFailureReason,omitempty"`
 }
 
+// Condition types of an Agent
+const (
+	// ConditionCredentialsProvisioned is True once the agent type's Postgres
+	// and Valkey credentials exist
+	ConditionCredentialsProvisioned = "CredentialsProvisioned"
+	// ConditionPodScheduled is True once the agent's pod has been bound to a
+	// node
+	ConditionPodScheduled = "PodScheduled"
+	// ConditionReady is True while the agent's pod is running and ready
+	ConditionReady = "Ready"
+	// ConditionDegraded is True while the agent is failing, e.g. restarting
+	// after its pod failed or out of restarts
+	ConditionDegraded = "Degraded"
+	// ConditionTTLExpiring is True while the agent is scheduled to be deleted
+	// once it has been inactive for its TTL
+	ConditionTTLExpiring = "TTLExpiring"
+)
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
 //+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Restarts",type=integer,JSONPath=`.status.restartCount`,priority=1
 //+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Agent is the Schema for the agents API
@@ -17,7 +17,9 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"os"
 	"path/filepath"
@@ -39,6 +41,7 @@ import (
 
 	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
 	"github.com/Algoluna/agent-operator/internal/apiserver"
+	"github.com/Algoluna/agent-operator/internal/apiserver/handlers"
 	"github.com/Algoluna/agent-operator/internal/controller"
 	// +kubebuilder:scaffold:imports
 )
@@ -64,6 +67,10 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var apiAddr, grpcAddr string
+	var apiCertPath, apiCertName, apiCertKey, apiClientCAPath string
+	var disableAPIAuth bool
+	var callerRateLimit, callerRateBurst, agentRateLimit, agentRateBurst int
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -82,6 +89,26 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&apiAddr, "api-bind-address", apiserver.DefaultBindAddress, "The address the agent API server binds to.")
+	flag.StringVar(&grpcAddr, "grpc-bind-address", apiserver.DefaultGRPCBindAddress, "The address the agent gRPC server binds to. "+
+		"It shares the TLS and authentication settings of the agent API. Use 0 to disable the gRPC server.")
+	flag.StringVar(&apiCertPath, "api-cert-path", "",
+		"The directory that contains the agent API server certificate. If set, the API is served over HTTPS.")
+	flag.StringVar(&apiCertName, "api-cert-name", "tls.crt", "The name of the agent API server certificate file.")
+	flag.StringVar(&apiCertKey, "api-cert-key", "tls.key", "The name of the agent API server key file.")
+	flag.StringVar(&apiClientCAPath, "api-client-ca", "",
+		"A CA bundle file. If set, clients of the agent API must present a certificate signed by one of its CAs.")
+	flag.BoolVar(&disableAPIAuth, "disable-api-auth", false,
+		"If set, requests to the agent API are not authenticated and authorized. Only use this for local development.")
+	flag.IntVar(&callerRateLimit, "api-caller-rate-limit", 600,
+		"The number of messages per minute each caller may send through the agent API. Use 0 for no limit.")
+	flag.IntVar(&callerRateBurst, "api-caller-rate-burst", 60,
+		"The number of messages a caller may send at once. Use 0 to allow a minute's worth.")
+	flag.IntVar(&agentRateLimit, "api-agent-rate-limit", 0,
+		"The number of messages per minute each agent accepts through the agent API, unless its "+
+			"spec.messaging.rateLimit is set. Use 0 for no limit.")
+	flag.IntVar(&agentRateBurst, "api-agent-rate-burst", 0,
+		"The number of messages an agent accepts at once. Use 0 to allow a minute's worth.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -179,6 +206,61 @@ func main() {
 		})
 	}
 
+	// The agent API is served over HTTPS when a certificate is provided, with
+	// optional verification of client certificates
+	var apiCertWatcher *certwatcher.CertWatcher
+	apiServerOptions := apiserver.Options{
+		BindAddress:   apiAddr,
+		SecureServing: len(apiCertPath) > 0,
+		TLSOpts:       tlsOpts,
+		DisableAuth:   disableAPIAuth,
+		// Limits are shared by the HTTP and gRPC servers
+		RateLimiter: handlers.NewRateLimiter(handlers.RateLimits{
+			PerCaller: agentsv1alpha1.RateLimit{MessagesPerMinute: int32(callerRateLimit), Burst: int32(callerRateBurst)},
+			PerAgent:  agentsv1alpha1.RateLimit{MessagesPerMinute: int32(agentRateLimit), Burst: int32(agentRateBurst)},
+		}),
+	}
+
+	if len(apiCertPath) > 0 {
+		setupLog.Info("Initializing API certificate watcher using provided certificates",
+			"api-cert-path", apiCertPath, "api-cert-name", apiCertName, "api-cert-key", apiCertKey)
+
+		var err error
+		apiCertWatcher, err = certwatcher.New(
+			filepath.Join(apiCertPath, apiCertName),
+			filepath.Join(apiCertPath, apiCertKey),
+		)
+		if err != nil {
+			setupLog.Error(err, "Failed to initialize API certificate watcher")
+			os.Exit(1)
+		}
+
+		apiServerOptions.TLSOpts = append(apiServerOptions.TLSOpts, func(config *tls.Config) {
+			config.GetCertificate = apiCertWatcher.GetCertificate
+		})
+
+		if len(apiClientCAPath) > 0 {
+			setupLog.Info("Requiring client certificates for the API", "api-client-ca", apiClientCAPath)
+			caPEM, err := os.ReadFile(apiClientCAPath)
+			if err != nil {
+				setupLog.Error(err, "Failed to read API client CA bundle")
+				os.Exit(1)
+			}
+			clientCAs := x509.NewCertPool()
+			if !clientCAs.AppendCertsFromPEM(caPEM) {
+				setupLog.Error(nil, "API client CA bundle contains no certificates", "api-client-ca", apiClientCAPath)
+				os.Exit(1)
+			}
+			apiServerOptions.TLSOpts = append(apiServerOptions.TLSOpts, func(config *tls.Config) {
+				config.ClientCAs = clientCAs
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+			})
+		}
+	} else if len(apiClientCAPath) > 0 {
+		setupLog.Error(nil, "--api-client-ca requires --api-cert-path")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                metricsServerOptions,
@@ -204,15 +286,26 @@ func main() {
 	}
 
 	if err = (&controller.AgentReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+		Scheme:    mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Agent")
 		os.Exit(1)
 	}
 
+	// Index agents by name so the API can find them in the cache without a namespace
+	if err := apiserver.SetupIndexes(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		setupLog.Error(err, "unable to index agents by name")
+		os.Exit(1)
+	}
+
+	// The HTTP and gRPC servers and the reply collector share one message
+	// handler
+	apiServerOptions.MessageHandler = handlers.NewMessageHandler(mgr.GetClient(), mgr.GetAPIReader(), mgr.GetScheme(), apiServerOptions.RateLimiter)
+
 	// Set up API server
-	apiServer, err := apiserver.SetupAPIServer(mgr.GetClient(), mgr.GetScheme())
+	apiServer, err := apiserver.SetupAPIServer(mgr.GetClient(), mgr.GetAPIReader(), mgr.GetScheme(), apiServerOptions)
 	if err != nil {
 		setupLog.Error(err, "unable to set up API server")
 		os.Exit(1)
@@ -224,7 +317,30 @@ func main() {
 		os.Exit(1)
 	}
 
-	setupLog.Info("API server initialized", "port", "8080", "endpoints", []string{"/api/v1/agents/{name}/messages"})
+	// Capture the replies to messages nobody is waiting for
+	if err := mgr.Add(handlers.NewReplyCollector(apiServerOptions.MessageHandler)); err != nil {
+		setupLog.Error(err, "unable to add reply collector to manager")
+		os.Exit(1)
+	}
+
+	setupLog.Info("API server initialized", "address", apiAddr, "secure", apiServerOptions.SecureServing, "agentPaths", []string{"/api/v1/agents/{name}", "/api/v1/namespaces/{namespace}/agents/{name}", "/api/v1/types/{type}/agents/{name}"},
+		"endpoints", []string{"/api/v1/agents", "{agent}", "{agent}/messages", "{agent}/messages/stream", "{agent}/messages/{id}", "{agent}/inbox", "{agent}/history", "{agent}/ws", "/api/v1/types/{type}/broadcast", "/api/v1/types/{type}/deadletters", "/api/v1/openapi.json"})
+
+	// Set up the gRPC server next to the HTTP API
+	if grpcAddr != "0" {
+		grpcServerOptions := apiServerOptions
+		grpcServerOptions.BindAddress = grpcAddr
+		grpcServer, err := apiserver.SetupGRPCServer(mgr.GetClient(), mgr.GetAPIReader(), mgr.GetScheme(), grpcServerOptions)
+		if err != nil {
+			setupLog.Error(err, "unable to set up gRPC server")
+			os.Exit(1)
+		}
+		if err := mgr.Add(grpcServer); err != nil {
+			setupLog.Error(err, "unable to add gRPC server to manager")
+			os.Exit(1)
+		}
+		setupLog.Info("gRPC server initialized", "address", grpcAddr, "secure", grpcServerOptions.SecureServing, "service", "algoluna.agents.v1.AgentService")
+	}
 
 	// +kubebuilder:scaffold:builder
 
@@ -236,6 +352,14 @@ func main() {
 		}
 	}
 
+	if apiCertWatcher != nil {
+		setupLog.Info("Adding API certificate watcher to manager")
+		if err := mgr.Add(apiCertWatcher); err != nil {
+			setupLog.Error(err, "unable to add API certificate watcher to manager")
+			os.Exit(1)
+		}
+	}
+
 	if webhookCertWatcher != nil {
 		setupLog.Info("Adding webhook certificate watcher to manager")
 		if err := mgr.Add(webhookCertWatcher); err != nil {
@@ -2,29 +2,115 @@
 package apiserver
 
 import (
+	"context"
+	"crypto/tls"
 	"net/http"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/Algoluna/agent-operator/internal/apiserver/auth"
 	"github.com/Algoluna/agent-operator/internal/apiserver/handlers"
 )
 
-// SetupAPIServer configures the HTTP API server for the operator
-func SetupAPIServer(client client.Client, scheme *runtime.Scheme) (*Server, error) {
+// DefaultBindAddress is the address the API server listens on by default
+const DefaultBindAddress = ":8080"
+
+// Options configures the HTTP API server
+type Options struct {
+	// BindAddress is the TCP address the server listens on, DefaultBindAddress if empty
+	BindAddress string
+
+	// SecureServing enables serving over HTTPS. TLSOpts must then provide the
+	// server certificate, e.g. through GetCertificate.
+	SecureServing bool
+
+	// TLSOpts is used to configure the TLS config of the server
+	TLSOpts []func(*tls.Config)
+
+	// DisableAuth turns off authentication and authorization of requests
+	DisableAuth bool
+
+	// RateLimiter limits sending messages, or nothing if nil. Servers set up
+	// with the same Options share its buckets.
+	RateLimiter *handlers.RateLimiter
+
+	// MessageHandler sends and tracks messages. Servers set up with the same
+	// Options share it, and with it their Valkey and Postgres connections.
+	// Each server creates its own, limited by RateLimiter, if it is nil.
+	MessageHandler *handlers.MessageHandler
+}
+
+// messageHandler returns the message handler servers set up with o use
+func (o Options) messageHandler(client client.Client, apiReader client.Reader, scheme *runtime.Scheme) *handlers.MessageHandler {
+	if o.MessageHandler != nil {
+		return o.MessageHandler
+	}
+	return handlers.NewMessageHandler(client, apiReader, scheme, o.RateLimiter)
+}
+
+// SetupIndexes registers the cache indexes the API server relies on. It must
+// be called before the manager is started.
+func SetupIndexes(ctx context.Context, indexer client.FieldIndexer) error {
+	return handlers.IndexAgentNames(ctx, indexer)
+}
+
+// SetupAPIServer configures the HTTP API server for the operator. apiReader
+// reads objects the manager's cache does not watch, such as schema
+// ConfigMaps, straight from the API server.
+func SetupAPIServer(client client.Client, apiReader client.Reader, scheme *runtime.Scheme, opts Options) (*Server, error) {
 	// Create the message handler
-	messageHandler := handlers.NewMessageHandler(client, scheme)
+	messageHandler := opts.messageHandler(client, apiReader, scheme)
+	deadLetterHandler := handlers.NewDeadLetterHandler(client, scheme)
+	agentHandler := handlers.NewAgentHandler(client, scheme)
+	broadcastHandler := handlers.NewBroadcastHandler(messageHandler)
 
 	// Set up routes
 	mux := http.NewServeMux()
 
 	// Add API routes
+	mux.Handle("/api/v1/agents", agentHandler)
+	mux.Handle("/api/v1/agents/{name}", agentHandler)
+	mux.Handle("/api/v1/namespaces/{namespace}/agents", agentHandler)
+	mux.Handle("/api/v1/namespaces/{namespace}/agents/{name}", agentHandler)
+	mux.Handle("/api/v1/types/{type}/agents", agentHandler)
+	mux.Handle("/api/v1/types/{type}/agents/{name}", agentHandler)
 	mux.Handle("/api/v1/agents/", messageHandler)
+	mux.Handle("/api/v1/namespaces/{namespace}/agents/", messageHandler)
+	mux.Handle("/api/v1/types/{type}/agents/", messageHandler)
+	mux.Handle("/api/v1/types/{type}/broadcast", broadcastHandler)
+	mux.Handle("/api/v1/types/{type}/deadletters", deadLetterHandler)
+	mux.Handle("/api/v1/types/{type}/deadletters/", deadLetterHandler)
+
+	// Every request must carry a bearer token of a user or service account
+	// that may perform the requested operation on agents
+	var api http.Handler = mux
+	if !opts.DisableAuth {
+		api = auth.NewMiddleware(client, mux)
+	}
+
+	// The API description is public so clients can be generated without a token
+	handler := http.NewServeMux()
+	handler.HandleFunc("GET /api/v1/openapi.json", handlers.ServeOpenAPI)
+	handler.Handle("/", api)
+
+	bindAddress := opts.BindAddress
+	if bindAddress == "" {
+		bindAddress = DefaultBindAddress
+	}
 
 	// Create the HTTP server
 	server := &http.Server{
-		Addr:    ":8080", // Default port
-		Handler: mux,
+		Addr:    bindAddress,
+		Handler: handler,
+	}
+	if opts.SecureServing {
+		server.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+		for _, opt := range opts.TLSOpts {
+			opt(server.TLSConfig)
+		}
 	}
 
 	return NewServer(server), nil
@@ -0,0 +1,400 @@
+// Package auth authenticates and authorizes requests to the operator API
+// against the Kubernetes API server, with TokenReview and SubjectAccessReview
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+var log = logf.Log.WithName("api-auth")
+
+// cacheTTL is how long the outcome of a review is reused
+const cacheTTL = 10 * time.Second
+
+// API group and resource requests are authorized against
+const (
+	agentGroup    = "agents.algoluna.com"
+	agentResource = "agents"
+)
+
+// Subresources of agents that API requests are authorized against
+const (
+	// SubresourceMessages covers sending messages to an agent and reading
+	// its replies, status, inbox and history
+	SubresourceMessages = "messages"
+	// SubresourceDeadLetters covers the dead letters of an agent type
+	SubresourceDeadLetters = "deadletters"
+)
+
+// User is an authenticated caller of the API
+type User struct {
+	Name   string
+	UID    string
+	Groups []string
+	Extra  map[string]authenticationv1.ExtraValue
+}
+
+type userKey struct{}
+
+// UserFrom returns the authenticated user of a request, or nil if the request
+// was not authenticated
+func UserFrom(ctx context.Context) *User {
+	user, _ := ctx.Value(userKey{}).(*User)
+	return user
+}
+
+// Middleware authenticates every request with the bearer token it carries
+// and authorizes it as an operation on agents
+type Middleware struct {
+	*reviewer
+	next http.Handler
+}
+
+// reviewer runs TokenReviews and SubjectAccessReviews, caching their outcome
+// for cacheTTL
+type reviewer struct {
+	client client.Client
+
+	mu    sync.Mutex
+	users map[string]cachedUser
+	sars  map[string]cachedDecision
+}
+
+type cachedUser struct {
+	user    *User
+	expires time.Time
+}
+
+type cachedDecision struct {
+	allowed bool
+	reason  string
+	expires time.Time
+}
+
+// NewMiddleware wraps next so it only serves authorized requests
+func NewMiddleware(client client.Client, next http.Handler) *Middleware {
+	return &Middleware{
+		reviewer: newReviewer(client),
+		next:     next,
+	}
+}
+
+func newReviewer(client client.Client) *reviewer {
+	return &reviewer{
+		client: client,
+		users:  map[string]cachedUser{},
+		sars:   map[string]cachedDecision{},
+	}
+}
+
+// ServeHTTP handles HTTP requests
+func (m *Middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	token, ok := bearerToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="agent-operator"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := m.authenticate(ctx, token)
+	if err != nil {
+		log.Error(err, "Failed to review token")
+		http.Error(w, "Authentication failed", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="agent-operator", error="invalid_token"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	attrs := ResourceAttributes(r)
+	if attrs == nil {
+		http.Error(w, "Invalid path", http.StatusNotFound)
+		return
+	}
+	allowed, reason, err := m.authorize(ctx, user, attrs)
+	if err != nil {
+		log.Error(err, "Failed to review access", "user", user.Name)
+		http.Error(w, "Authorization failed", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		msg := fmt.Sprintf("User %q cannot %s %s", user.Name, attrs.Verb, describe(attrs))
+		if reason != "" {
+			msg += ": " + reason
+		}
+		http.Error(w, msg, http.StatusForbidden)
+		return
+	}
+
+	m.next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, userKey{}, user)))
+}
+
+// authenticate reviews a bearer token, returning nil if it is not valid
+func (m *reviewer) authenticate(ctx context.Context, token string) (*User, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+
+	m.mu.Lock()
+	cached, ok := m.users[key]
+	m.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.user, nil
+	}
+
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}
+	if err := m.client.Create(ctx, review); err != nil {
+		return nil, err
+	}
+
+	var user *User
+	if review.Status.Authenticated {
+		user = &User{
+			Name:   review.Status.User.Username,
+			UID:    review.Status.User.UID,
+			Groups: review.Status.User.Groups,
+			Extra:  review.Status.User.Extra,
+		}
+	}
+
+	m.mu.Lock()
+	m.expire()
+	m.users[key] = cachedUser{user: user, expires: time.Now().Add(cacheTTL)}
+	m.mu.Unlock()
+	return user, nil
+}
+
+// authorize asks the API server whether user may perform the operation
+// described by attrs
+func (m *reviewer) authorize(ctx context.Context, user *User, attrs *authorizationv1.ResourceAttributes) (bool, string, error) {
+	key := strings.Join([]string{user.UID, user.Name, attrs.Verb, attrs.Namespace, attrs.Subresource, attrs.Name}, "\x00")
+
+	m.mu.Lock()
+	cached, ok := m.sars[key]
+	m.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.allowed, cached.reason, nil
+	}
+
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: attrs,
+			User:               user.Name,
+			UID:                user.UID,
+			Groups:             user.Groups,
+			Extra:              extra,
+		},
+	}
+	if err := m.client.Create(ctx, review); err != nil {
+		return false, "", err
+	}
+
+	m.mu.Lock()
+	m.expire()
+	m.sars[key] = cachedDecision{
+		allowed: review.Status.Allowed,
+		reason:  review.Status.Reason,
+		expires: time.Now().Add(cacheTTL),
+	}
+	m.mu.Unlock()
+	return review.Status.Allowed, review.Status.Reason, nil
+}
+
+// expire drops outdated cache entries. The caller must hold m.mu.
+func (m *reviewer) expire() {
+	now := time.Now()
+	for key, cached := range m.users {
+		if now.After(cached.expires) {
+			delete(m.users, key)
+		}
+	}
+	for key, cached := range m.sars {
+		if now.After(cached.expires) {
+			delete(m.sars, key)
+		}
+	}
+}
+
+// ResourceAttributes maps an API request to the operation on agents it is
+// authorized as, or nil if the path is not part of the API. Agent paths may
+// be prefixed with /namespaces/{namespace} or /types/{type}, which scopes the
+// check to the namespace of the agent; without a prefix the caller needs
+// access to the agent in all namespaces.
+//
+//	/api/v1/agents                         agents
+//	/api/v1/agents/{name}                  agents, named
+//	/api/v1/agents/{name}/...              agents/messages, named
+//	/api/v1/types/{type}/deadletters[/...] agents/deadletters
+//	/api/v1/types/{type}/broadcast         agents/messages
+//
+// Opening a WebSocket session on /api/v1/agents/{name}/ws is authorized as
+// create on agents/messages, as the session is used to send messages.
+func ResourceAttributes(r *http.Request) *authorizationv1.ResourceAttributes {
+	pathParts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	if len(pathParts) < 4 || pathParts[1] != "api" || pathParts[2] != "v1" {
+		return nil
+	}
+	parts := pathParts[3:]
+
+	attrs := &authorizationv1.ResourceAttributes{
+		Group:    agentGroup,
+		Resource: agentResource,
+		Verb:     verb(r.Method),
+	}
+
+	// Dead letters belong to an agent type rather than to a single agent
+	if len(parts) >= 3 && parts[0] == "types" && parts[2] == "deadletters" {
+		attrs.Namespace = agentTypeNamespace(parts[1])
+		attrs.Subresource = SubresourceDeadLetters
+		if attrs.Verb == "get" && len(parts) == 3 {
+			attrs.Verb = "list"
+		}
+		return attrs
+	}
+
+	// A broadcast sends messages to all agents of a type
+	if len(parts) == 3 && parts[0] == "types" && parts[2] == "broadcast" {
+		attrs.Namespace = agentTypeNamespace(parts[1])
+		attrs.Subresource = SubresourceMessages
+		return attrs
+	}
+
+	if len(parts) >= 3 && parts[2] == "agents" {
+		switch parts[0] {
+		case "namespaces":
+			attrs.Namespace = parts[1]
+		case "types":
+			attrs.Namespace = agentTypeNamespace(parts[1])
+		default:
+			return nil
+		}
+		parts = parts[2:]
+	}
+	if parts[0] != "agents" {
+		return nil
+	}
+
+	switch len(parts) {
+	case 1:
+		if attrs.Verb == "get" {
+			attrs.Verb = "list"
+		}
+	case 2:
+		attrs.Name = parts[1]
+	default:
+		attrs.Name = parts[1]
+		attrs.Subresource = SubresourceMessages
+		if parts[2] == "ws" {
+			attrs.Verb = "create"
+		}
+	}
+	return attrs
+}
+
+// AgentAttributes describes an operation on agents, or on one of their
+// subresources, for callers that do not go through ResourceAttributes. An
+// empty namespace stands for all namespaces.
+func AgentAttributes(verb, namespace, name, subresource string) *authorizationv1.ResourceAttributes {
+	return &authorizationv1.ResourceAttributes{
+		Group:       agentGroup,
+		Resource:    agentResource,
+		Verb:        verb,
+		Namespace:   namespace,
+		Name:        name,
+		Subresource: subresource,
+	}
+}
+
+// agentTypeNamespace returns the namespace agents of a type live in
+func agentTypeNamespace(agentType string) string {
+	return "agent-" + agentType
+}
+
+// verb returns the Kubernetes verb for an HTTP method
+func verb(method string) string {
+	switch method {
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return "get"
+	}
+}
+
+func describe(attrs *authorizationv1.ResourceAttributes) string {
+	resource := attrs.Resource
+	if attrs.Subresource != "" {
+		resource += "/" + attrs.Subresource
+	}
+	if attrs.Name != "" {
+		return fmt.Sprintf("%s %q", resource, attrs.Name)
+	}
+	return resource
+}
+
+// bearerTokenProtocolPrefix marks a WebSocket subprotocol carrying a bearer
+// token, for browsers that cannot set headers on WebSocket requests. The
+// format is the one the Kubernetes API server accepts.
+const bearerTokenProtocolPrefix = "base64url.bearer.authorization.k8s.io."
+
+// bearerToken returns the token of the request's Authorization header or,
+// for WebSocket requests without one, of its bearer token subprotocol
+func bearerToken(r *http.Request) (string, bool) {
+	if header := r.Header.Get("Authorization"); header != "" || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return parseBearer(header)
+	}
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(value, ",") {
+			encoded, ok := strings.CutPrefix(strings.TrimSpace(protocol), bearerTokenProtocolPrefix)
+			if !ok {
+				continue
+			}
+			token, err := base64.RawURLEncoding.DecodeString(encoded)
+			if err != nil || len(token) == 0 {
+				return "", false
+			}
+			return string(token), true
+		}
+	}
+	return "", false
+}
+
+// parseBearer returns the token of an Authorization header value
+func parseBearer(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
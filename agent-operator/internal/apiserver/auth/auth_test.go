@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+func TestResourceAttributes(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   *authorizationv1.ResourceAttributes
+	}{
+		{
+			name:   "list agents in all namespaces",
+			method: http.MethodGet,
+			path:   "/api/v1/agents",
+			want:   &authorizationv1.ResourceAttributes{Verb: "list"},
+		},
+		{
+			name:   "create agent in a namespace",
+			method: http.MethodPost,
+			path:   "/api/v1/namespaces/team-a/agents",
+			want:   &authorizationv1.ResourceAttributes{Verb: "create", Namespace: "team-a"},
+		},
+		{
+			name:   "unprefixed agent in all namespaces",
+			method: http.MethodGet,
+			path:   "/api/v1/agents/hello",
+			want:   &authorizationv1.ResourceAttributes{Verb: "get", Name: "hello"},
+		},
+		{
+			name:   "delete agent by type",
+			method: http.MethodDelete,
+			path:   "/api/v1/types/hello-agent/agents/hello",
+			want:   &authorizationv1.ResourceAttributes{Verb: "delete", Namespace: "agent-hello-agent", Name: "hello"},
+		},
+		{
+			name:   "send message",
+			method: http.MethodPost,
+			path:   "/api/v1/namespaces/team-a/agents/hello/messages",
+			want:   &authorizationv1.ResourceAttributes{Verb: "create", Namespace: "team-a", Name: "hello", Subresource: SubresourceMessages},
+		},
+		{
+			name:   "message status with trailing slash",
+			method: http.MethodGet,
+			path:   "/api/v1/agents/hello/messages/m-1/",
+			want:   &authorizationv1.ResourceAttributes{Verb: "get", Name: "hello", Subresource: SubresourceMessages},
+		},
+		{
+			name:   "websocket is create",
+			method: http.MethodGet,
+			path:   "/api/v1/types/hello-agent/agents/hello/ws",
+			want:   &authorizationv1.ResourceAttributes{Verb: "create", Namespace: "agent-hello-agent", Name: "hello", Subresource: SubresourceMessages},
+		},
+		{
+			name:   "list dead letters",
+			method: http.MethodGet,
+			path:   "/api/v1/types/hello-agent/deadletters",
+			want:   &authorizationv1.ResourceAttributes{Verb: "list", Namespace: "agent-hello-agent", Subresource: SubresourceDeadLetters},
+		},
+		{
+			name:   "get dead letter",
+			method: http.MethodGet,
+			path:   "/api/v1/types/hello-agent/deadletters/1-0",
+			want:   &authorizationv1.ResourceAttributes{Verb: "get", Namespace: "agent-hello-agent", Subresource: SubresourceDeadLetters},
+		},
+		{
+			name:   "replay dead letter",
+			method: http.MethodPost,
+			path:   "/api/v1/types/hello-agent/deadletters/1-0/replay",
+			want:   &authorizationv1.ResourceAttributes{Verb: "create", Namespace: "agent-hello-agent", Subresource: SubresourceDeadLetters},
+		},
+		{
+			name:   "broadcast is unnamed",
+			method: http.MethodPost,
+			path:   "/api/v1/types/hello-agent/broadcast",
+			want:   &authorizationv1.ResourceAttributes{Verb: "create", Namespace: "agent-hello-agent", Subresource: SubresourceMessages},
+		},
+		{name: "other api version", method: http.MethodGet, path: "/api/v2/agents"},
+		{name: "unknown collection", method: http.MethodGet, path: "/api/v1/pods"},
+		{name: "unknown prefix", method: http.MethodGet, path: "/api/v1/clusters/c/agents"},
+		{name: "too short", method: http.MethodGet, path: "/api/v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResourceAttributes(httptest.NewRequest(tt.method, tt.path, nil))
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("expected no attributes, got %+v", got)
+				}
+				return
+			}
+			tt.want.Group = agentGroup
+			tt.want.Resource = agentResource
+			if got == nil || *got != *tt.want {
+				t.Fatalf("attributes = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte("ws-token"))
+	tests := []struct {
+		name      string
+		header    http.Header
+		wantToken string
+		wantOK    bool
+	}{
+		{
+			name:      "authorization header",
+			header:    http.Header{"Authorization": {"Bearer abc"}},
+			wantToken: "abc",
+			wantOK:    true,
+		},
+		{
+			name:      "lowercase scheme",
+			header:    http.Header{"Authorization": {"bearer  abc "}},
+			wantToken: "abc",
+			wantOK:    true,
+		},
+		{name: "basic auth", header: http.Header{"Authorization": {"Basic YTpi"}}},
+		{name: "empty token", header: http.Header{"Authorization": {"Bearer "}}},
+		{name: "no credentials", header: http.Header{}},
+		{
+			name: "websocket subprotocol",
+			header: http.Header{
+				"Upgrade":                {"websocket"},
+				"Sec-Websocket-Protocol": {"agentbox.v1, " + bearerTokenProtocolPrefix + encoded},
+			},
+			wantToken: "ws-token",
+			wantOK:    true,
+		},
+		{
+			name: "websocket subprotocol in a separate header",
+			header: http.Header{
+				"Upgrade":                {"WebSocket"},
+				"Sec-Websocket-Protocol": {"agentbox.v1", bearerTokenProtocolPrefix + encoded},
+			},
+			wantToken: "ws-token",
+			wantOK:    true,
+		},
+		{
+			name: "authorization header wins over subprotocol",
+			header: http.Header{
+				"Authorization":          {"Bearer abc"},
+				"Upgrade":                {"websocket"},
+				"Sec-Websocket-Protocol": {bearerTokenProtocolPrefix + encoded},
+			},
+			wantToken: "abc",
+			wantOK:    true,
+		},
+		{
+			name: "subprotocol ignored without upgrade",
+			header: http.Header{
+				"Sec-Websocket-Protocol": {bearerTokenProtocolPrefix + encoded},
+			},
+		},
+		{
+			name: "invalid subprotocol encoding",
+			header: http.Header{
+				"Upgrade":                {"websocket"},
+				"Sec-Websocket-Protocol": {bearerTokenProtocolPrefix + "not*base64"},
+			},
+		},
+		{
+			name: "websocket without token",
+			header: http.Header{
+				"Upgrade":                {"websocket"},
+				"Sec-Websocket-Protocol": {"agentbox.v1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/agents/hello/ws", nil)
+			r.Header = tt.header
+			token, ok := bearerToken(r)
+			if token != tt.wantToken || ok != tt.wantOK {
+				t.Fatalf("bearerToken = %q, %v, want %q, %v", token, ok, tt.wantToken, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GRPCAuth authenticates gRPC calls with the bearer token of their
+// "authorization" metadata. Unlike HTTP requests, calls do not name the agent
+// they are about in their path, so services authorize each call themselves
+// with Authorize once they have read the request.
+type GRPCAuth struct {
+	*reviewer
+}
+
+// NewGRPCAuth creates the interceptors and authorizer for the gRPC server
+func NewGRPCAuth(client client.Client) *GRPCAuth {
+	return &GRPCAuth{reviewer: newReviewer(client)}
+}
+
+// UnaryInterceptor authenticates unary calls
+func (a *GRPCAuth) UnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := a.authenticateCall(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor authenticates streaming calls
+func (a *GRPCAuth) StreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticateCall(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticateCall reviews the bearer token of a call and returns a context
+// carrying the authenticated user
+func (a *GRPCAuth) authenticateCall(ctx context.Context) (context.Context, error) {
+	var token string
+	ok := false
+	if md, found := metadata.FromIncomingContext(ctx); found {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token, ok = parseBearer(values[0])
+		}
+	}
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+
+	user, err := a.authenticate(ctx, token)
+	if err != nil {
+		log.Error(err, "Failed to review token")
+		return nil, status.Error(codes.Internal, "Authentication failed")
+	}
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	return context.WithValue(ctx, userKey{}, user), nil
+}
+
+// Authorize checks that the authenticated user of a call may perform the
+// operation described by attrs, returning a gRPC status error if not
+func (a *GRPCAuth) Authorize(ctx context.Context, attrs *authorizationv1.ResourceAttributes) error {
+	user := UserFrom(ctx)
+	if user == nil {
+		return status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	allowed, reason, err := a.authorize(ctx, user, attrs)
+	if err != nil {
+		log.Error(err, "Failed to review access", "user", user.Name)
+		return status.Error(codes.Internal, "Authorization failed")
+	}
+	if !allowed {
+		msg := fmt.Sprintf("User %q cannot %s %s", user.Name, attrs.Verb, describe(attrs))
+		if reason != "" {
+			msg += ": " + reason
+		}
+		return status.Error(codes.PermissionDenied, msg)
+	}
+	return nil
+}
+
+// authenticatedStream replaces the context of a server stream
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,105 @@
+package apiserver
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Algoluna/agent-operator/internal/apiserver/auth"
+	"github.com/Algoluna/agent-operator/internal/apiserver/handlers"
+	"github.com/Algoluna/agent-operator/pkg/agentrpc"
+)
+
+// DefaultGRPCBindAddress is the address the gRPC server listens on by default
+const DefaultGRPCBindAddress = ":9090"
+
+// grpcShutdownTimeout is how long open calls, such as reply streams, may
+// take to finish before the gRPC server is stopped forcibly
+const grpcShutdownTimeout = 10 * time.Second
+
+// GRPCServer implements manager.Runnable for the gRPC API server
+type GRPCServer struct {
+	grpcServer  *grpc.Server
+	bindAddress string
+}
+
+// SetupGRPCServer configures the gRPC API server for the operator. It is
+// configured with the same Options as the HTTP API server, except that an
+// empty BindAddress defaults to DefaultGRPCBindAddress.
+func SetupGRPCServer(client client.Client, apiReader client.Reader, scheme *runtime.Scheme, opts Options) (*GRPCServer, error) {
+	var serverOpts []grpc.ServerOption
+	if opts.SecureServing {
+		tlsConfig := &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+		for _, opt := range opts.TLSOpts {
+			opt(tlsConfig)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	// Every call must carry a bearer token of a user or service account
+	// that may perform the requested operation on agents
+	var authz handlers.Authorizer
+	if !opts.DisableAuth {
+		grpcAuth := auth.NewGRPCAuth(client)
+		serverOpts = append(serverOpts,
+			grpc.UnaryInterceptor(grpcAuth.UnaryInterceptor),
+			grpc.StreamInterceptor(grpcAuth.StreamInterceptor),
+		)
+		authz = grpcAuth
+	}
+
+	server := grpc.NewServer(serverOpts...)
+	agentrpc.RegisterAgentServiceServer(server, handlers.NewAgentService(opts.messageHandler(client, apiReader, scheme), authz))
+
+	bindAddress := opts.BindAddress
+	if bindAddress == "" {
+		bindAddress = DefaultGRPCBindAddress
+	}
+
+	return &GRPCServer{
+		grpcServer:  server,
+		bindAddress: bindAddress,
+	}, nil
+}
+
+// Start implements manager.Runnable
+func (s *GRPCServer) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.bindAddress)
+	if err != nil {
+		return err
+	}
+
+	errCh := make(chan error)
+
+	go func() {
+		if err := s.grpcServer.Serve(listener); err != nil && err != grpc.ErrServerStopped {
+			errCh <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		// Graceful shutdown, cutting off calls that do not end in time
+		stopped := make(chan struct{})
+		go func() {
+			s.grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(grpcShutdownTimeout):
+			s.grpcServer.Stop()
+		}
+		return nil
+	case err := <-errCh:
+		return err
+	}
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+)
+
+// maxAgentBodySize limits the size of agent create and update requests
+const maxAgentBodySize = 1 << 20
+
+// AgentHandler handles API requests that manage Agent resources:
+//
+//	GET    /api/v1/agents          list agents
+//	POST   /api/v1/agents          create an agent
+//	GET    /api/v1/agents/{name}   get an agent
+//	PUT    /api/v1/agents/{name}   replace an agent's spec
+//	DELETE /api/v1/agents/{name}   delete an agent
+//
+// The paths may be prefixed with /namespaces/{namespace} or /types/{type}
+// like the messaging paths.
+type AgentHandler struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// NewAgentHandler creates a new agent handler
+func NewAgentHandler(client client.Client, scheme *runtime.Scheme) *AgentHandler {
+	return &AgentHandler{
+		client: client,
+		scheme: scheme,
+	}
+}
+
+// ServeHTTP handles HTTP requests
+func (h *AgentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ref := agentRef{
+		Namespace: r.PathValue("namespace"),
+		Type:      r.PathValue("type"),
+		Name:      r.PathValue("name"),
+	}
+
+	if ref.Name == "" {
+		switch r.Method {
+		case http.MethodGet:
+			h.handleListAgents(w, r, ref)
+		case http.MethodPost:
+			h.handleCreateAgent(w, r, ref)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	agent, err := resolveAgent(r.Context(), h.client, ref)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeAgentJSON(w, http.StatusOK, agent)
+	case http.MethodPut:
+		h.handleUpdateAgent(w, r, agent)
+	case http.MethodDelete:
+		h.handleDeleteAgent(w, r, agent)
+	}
+}
+
+// handleListAgents lists agents, optionally of a single namespace or type
+func (h *AgentHandler) handleListAgents(w http.ResponseWriter, r *http.Request, ref agentRef) {
+	agentType := ref.Type
+	if agentType == "" {
+		agentType = r.URL.Query().Get("type")
+	}
+
+	opts := []client.ListOption{}
+	if ref.Namespace != "" {
+		opts = append(opts, client.InNamespace(ref.Namespace))
+	} else if agentType != "" {
+		opts = append(opts, client.InNamespace(agentTypeNamespace(agentType)))
+	}
+
+	var agents agentsv1alpha1.AgentList
+	if err := h.client.List(r.Context(), &agents, opts...); err != nil {
+		http.Error(w, fmt.Sprintf("Error listing agents: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]agentsv1alpha1.Agent, 0, len(agents.Items))
+	for _, agent := range agents.Items {
+		if agentType != "" && agent.Spec.Type != agentType {
+			continue
+		}
+		setAgentTypeMeta(&agent)
+		items = append(items, agent)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items": items,
+	})
+}
+
+// handleCreateAgent creates an agent in the namespace of its type
+func (h *AgentHandler) handleCreateAgent(w http.ResponseWriter, r *http.Request, ref agentRef) {
+	var req agentsv1alpha1.Agent
+	if err := decodeAgent(r, &req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if ref.Type != "" && req.Spec.Type != "" && req.Spec.Type != ref.Type {
+		http.Error(w, fmt.Sprintf("spec.type %q does not match the agent type %q of the path", req.Spec.Type, ref.Type), http.StatusBadRequest)
+		return
+	}
+	if req.Spec.Type == "" {
+		req.Spec.Type = ref.Type
+	}
+	if errs := validateAgent(&req); len(errs) > 0 {
+		http.Error(w, fmt.Sprintf("Invalid agent: %s", strings.Join(errs, "; ")), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Agents must live in the namespace of their type, see the reconciler
+	namespace := agentTypeNamespace(req.Spec.Type)
+	for _, ns := range []string{ref.Namespace, req.Namespace} {
+		if ns != "" && ns != namespace {
+			http.Error(w, fmt.Sprintf("Agents of type %q must be created in namespace %q", req.Spec.Type, namespace), http.StatusBadRequest)
+			return
+		}
+	}
+
+	agent := &agentsv1alpha1.Agent{}
+	agent.Name = req.Name
+	agent.Namespace = namespace
+	agent.Labels = req.Labels
+	agent.Annotations = req.Annotations
+	agent.Spec = req.Spec
+
+	if err := h.client.Create(r.Context(), agent); err != nil {
+		writeAgentError(w, "create", err)
+		return
+	}
+	log.Info("Created agent via API", "agent", agent.Name, "namespace", agent.Namespace, "user", senderOf(r))
+
+	w.Header().Set("Location", agentPath(agent))
+	writeAgentJSON(w, http.StatusCreated, agent)
+}
+
+// handleUpdateAgent replaces an agent's spec, labels and annotations. If the
+// request carries a resourceVersion, the update only succeeds if the agent
+// has not changed since.
+func (h *AgentHandler) handleUpdateAgent(w http.ResponseWriter, r *http.Request, agent *agentsv1alpha1.Agent) {
+	var req agentsv1alpha1.Agent
+	if err := decodeAgent(r, &req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if req.Name != "" && req.Name != agent.Name {
+		http.Error(w, "metadata.name cannot be changed", http.StatusBadRequest)
+		return
+	}
+	if req.Namespace != "" && req.Namespace != agent.Namespace {
+		http.Error(w, "metadata.namespace cannot be changed", http.StatusBadRequest)
+		return
+	}
+	if req.Spec.Type != agent.Spec.Type {
+		http.Error(w, "spec.type cannot be changed", http.StatusBadRequest)
+		return
+	}
+	req.Name = agent.Name
+	if errs := validateAgent(&req); len(errs) > 0 {
+		http.Error(w, fmt.Sprintf("Invalid agent: %s", strings.Join(errs, "; ")), http.StatusUnprocessableEntity)
+		return
+	}
+
+	updated := agent.DeepCopy()
+	updated.Spec = req.Spec
+	if req.Labels != nil {
+		updated.Labels = req.Labels
+	}
+	if req.Annotations != nil {
+		updated.Annotations = req.Annotations
+	}
+	if req.ResourceVersion != "" {
+		updated.ResourceVersion = req.ResourceVersion
+	}
+
+	if err := h.client.Update(r.Context(), updated); err != nil {
+		writeAgentError(w, "update", err)
+		return
+	}
+	log.Info("Updated agent via API", "agent", updated.Name, "namespace", updated.Namespace, "user", senderOf(r))
+
+	writeAgentJSON(w, http.StatusOK, updated)
+}
+
+// handleDeleteAgent deletes an agent
+func (h *AgentHandler) handleDeleteAgent(w http.ResponseWriter, r *http.Request, agent *agentsv1alpha1.Agent) {
+	if err := h.client.Delete(r.Context(), agent); err != nil {
+		writeAgentError(w, "delete", err)
+		return
+	}
+	log.Info("Deleted agent via API", "agent", agent.Name, "namespace", agent.Namespace, "user", senderOf(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeAgent reads an Agent from a request body, rejecting fields that are
+// not part of the Agent schema
+func decodeAgent(r *http.Request, agent *agentsv1alpha1.Agent) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxAgentBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(agent); err != nil {
+		return err
+	}
+	if agent.APIVersion != "" && agent.APIVersion != agentsv1alpha1.GroupVersion.String() {
+		return fmt.Errorf("unsupported apiVersion %q", agent.APIVersion)
+	}
+	if agent.Kind != "" && agent.Kind != "Agent" {
+		return fmt.Errorf("unsupported kind %q", agent.Kind)
+	}
+	return nil
+}
+
+// validateAgent checks the parts of an agent the CRD schema cannot express
+// or that must hold before the agent's namespace can be derived
+func validateAgent(agent *agentsv1alpha1.Agent) []string {
+	var errs []string
+	if agent.Name == "" {
+		errs = append(errs, "metadata.name is required")
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(agent.Name) {
+			errs = append(errs, "metadata.name: "+msg)
+		}
+	}
+	if agent.Spec.Type == "" {
+		errs = append(errs, "spec.type is required")
+	} else {
+		// The type names the agent's namespace
+		for _, msg := range validation.IsDNS1123Label(agentTypeNamespace(agent.Spec.Type)) {
+			errs = append(errs, "spec.type: "+msg)
+		}
+	}
+	if agent.Spec.Image == "" {
+		errs = append(errs, "spec.image is required")
+	}
+	if agent.Spec.MaxRestarts < -1 {
+		errs = append(errs, "spec.maxRestarts must be -1 or greater")
+	}
+	if agent.Spec.TTL < 0 {
+		errs = append(errs, "spec.ttl must not be negative")
+	}
+	return errs
+}
+
+// writeAgentError reports an error returned by the Kubernetes API
+func writeAgentError(w http.ResponseWriter, action string, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case apierrors.IsAlreadyExists(err), apierrors.IsConflict(err):
+		code = http.StatusConflict
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		code = http.StatusUnprocessableEntity
+	case apierrors.IsNotFound(err):
+		code = http.StatusNotFound
+	case apierrors.IsForbidden(err):
+		code = http.StatusForbidden
+	}
+	http.Error(w, fmt.Sprintf("Failed to %s agent: %v", action, err), code)
+}
+
+func writeAgentJSON(w http.ResponseWriter, code int, agent *agentsv1alpha1.Agent) {
+	setAgentTypeMeta(agent)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(agent)
+}
+
+// setAgentTypeMeta fills in apiVersion and kind, which objects read from the
+// cache do not carry
+func setAgentTypeMeta(agent *agentsv1alpha1.Agent) {
+	agent.APIVersion = agentsv1alpha1.GroupVersion.String()
+	agent.Kind = "Agent"
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+)
+
+// AgentNameField indexes agents by name across namespaces, so an agent can be
+// found in the informer cache by its name alone
+const AgentNameField = "metadata.name"
+
+// IndexAgentNames registers the AgentNameField index with the manager's cache.
+// It must be called before the manager is started.
+func IndexAgentNames(ctx context.Context, indexer client.FieldIndexer) error {
+	return indexer.IndexField(ctx, &agentsv1alpha1.Agent{}, AgentNameField, func(obj client.Object) []string {
+		return []string{obj.GetName()}
+	})
+}
+
+// agentResource is used to report agents that cannot be found
+var agentResource = agentsv1alpha1.GroupVersion.WithResource("agents").GroupResource()
+
+// agentRef identifies the agent an API request is about. Namespace and Type
+// are optional; without them the agent is looked up by name alone.
+type agentRef struct {
+	Namespace string
+	Type      string
+	Name      string
+}
+
+// agentTypeNamespace returns the namespace agents of a type live in
+func agentTypeNamespace(agentType string) string {
+	return fmt.Sprintf("agent-%s", agentType)
+}
+
+// parseAgentPath splits an API path into the agent it refers to and the
+// remaining path segments. Accepted formats:
+//
+//	/api/v1/agents/{agent-name}/...
+//	/api/v1/namespaces/{namespace}/agents/{agent-name}/...
+//	/api/v1/types/{agent-type}/agents/{agent-name}/...
+func parseAgentPath(path string) (agentRef, []string, bool) {
+	pathParts := strings.Split(path, "/")
+	if len(pathParts) < 5 || pathParts[0] != "" || pathParts[1] != "api" || pathParts[2] != "v1" {
+		return agentRef{}, nil, false
+	}
+	parts := pathParts[3:]
+
+	var ref agentRef
+	switch parts[0] {
+	case "agents":
+	case "namespaces", "types":
+		if len(parts) < 4 || parts[1] == "" || parts[2] != "agents" {
+			return agentRef{}, nil, false
+		}
+		if parts[0] == "namespaces" {
+			ref.Namespace = parts[1]
+		} else {
+			ref.Type = parts[1]
+		}
+		parts = parts[2:]
+	default:
+		return agentRef{}, nil, false
+	}
+
+	ref.Name = parts[1]
+	if ref.Name == "" {
+		return agentRef{}, nil, false
+	}
+	return ref, parts[2:], true
+}
+
+// resolveAgent finds the agent a request refers to in the informer cache
+func resolveAgent(ctx context.Context, c client.Reader, ref agentRef) (*agentsv1alpha1.Agent, error) {
+	namespace := ref.Namespace
+	if namespace == "" && ref.Type != "" {
+		namespace = agentTypeNamespace(ref.Type)
+	}
+
+	if namespace != "" {
+		var agent agentsv1alpha1.Agent
+		if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, &agent); err != nil {
+			return nil, err
+		}
+		if ref.Type != "" && agent.Spec.Type != ref.Type {
+			return nil, apierrors.NewNotFound(agentResource, ref.Name)
+		}
+		return &agent, nil
+	}
+
+	var agents agentsv1alpha1.AgentList
+	if err := c.List(ctx, &agents, client.MatchingFields{AgentNameField: ref.Name}); err != nil {
+		return nil, err
+	}
+	switch len(agents.Items) {
+	case 0:
+		return nil, apierrors.NewNotFound(agentResource, ref.Name)
+	case 1:
+		return &agents.Items[0], nil
+	default:
+		namespaces := make([]string, 0, len(agents.Items))
+		for _, agent := range agents.Items {
+			namespaces = append(namespaces, agent.Namespace)
+		}
+		return nil, apierrors.NewConflict(agentResource, ref.Name,
+			fmt.Errorf("agent name is ambiguous, use /api/v1/namespaces/{namespace}/agents/%s with one of %s",
+				ref.Name, strings.Join(namespaces, ", ")))
+	}
+}
+
+// writeLookupError reports an error returned by resolveAgent
+func writeLookupError(w http.ResponseWriter, err error) {
+	switch {
+	case apierrors.IsNotFound(err):
+		http.Error(w, fmt.Sprintf("Agent not found: %v", err), http.StatusNotFound)
+	case apierrors.IsConflict(err):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, fmt.Sprintf("Error looking up agent: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// agentPath returns the canonical API path of an agent
+func agentPath(agent *agentsv1alpha1.Agent) string {
+	return fmt.Sprintf("/api/v1/namespaces/%s/agents/%s", agent.Namespace, agent.Name)
+}
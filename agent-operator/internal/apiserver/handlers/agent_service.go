@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+	"github.com/Algoluna/agent-operator/internal/apiserver/auth"
+	"github.com/Algoluna/agent-operator/pkg/agentrpc"
+	"github.com/Algoluna/agent-operator/pkg/wire"
+)
+
+// Authorizer checks whether the caller of a gRPC call may perform an
+// operation on agents, returning a gRPC status error if not
+type Authorizer interface {
+	Authorize(ctx context.Context, attrs *authorizationv1.ResourceAttributes) error
+}
+
+// AgentService implements the gRPC AgentService on the same streams and
+// message records as the HTTP API
+type AgentService struct {
+	agentrpc.UnimplementedAgentServiceServer
+
+	messages *MessageHandler
+	authz    Authorizer
+}
+
+// NewAgentService creates the gRPC agent service, sending messages through
+// the given message handler. Calls are not authorized if authz is nil.
+func NewAgentService(messages *MessageHandler, authz Authorizer) *AgentService {
+	return &AgentService{
+		messages: messages,
+		authz:    authz,
+	}
+}
+
+// SendMessage adds a message to an agent's inbox and, unless the request is
+// async, waits for the reply
+func (s *AgentService) SendMessage(ctx context.Context, req *agentrpc.SendMessageRequest) (*agentrpc.SendMessageResponse, error) {
+	if req.GetPayload() == nil {
+		return nil, status.Error(codes.InvalidArgument, "payload is required")
+	}
+	agent, err := s.resolve(ctx, req.GetAgent(), "create")
+	if err != nil {
+		return nil, err
+	}
+	if delay, ok := s.messages.limiter.reserve(callerKeyOf(ctx), agent); !ok {
+		return nil, retryError(codes.ResourceExhausted,
+			fmt.Sprintf("Rate limit exceeded, retry in %ds", retryAfterSeconds(delay)), delay)
+	}
+	payload, err := protojson.Marshal(req.GetPayload())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid payload: %v", err)
+	}
+	if err := s.messages.schemas.validateInput(ctx, agent, payload); err != nil {
+		return nil, schemaError(err, codes.InvalidArgument, "Invalid payload", "Error loading input schema")
+	}
+
+	timeout := defaultTimeout
+	if req.GetAsync() {
+		timeout = defaultAsyncTimeout
+	}
+	if req.GetTimeoutSeconds() > 0 {
+		timeout = time.Duration(req.GetTimeoutSeconds()) * time.Second
+	}
+
+	rec, cursor, err := s.messages.enqueueMessage(ctx, agent, uuid.NewString(), callerOf(ctx), payload, timeout)
+	var full *inboxFullError
+	if errors.As(err, &full) {
+		return nil, retryError(codes.Unavailable, fmt.Sprintf("Agent is not keeping up: %v", err), inboxFullRetryAfter)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to send message: %v", err)
+	}
+	if req.GetAsync() {
+		return messageResponse(rec, ""), nil
+	}
+
+	replyID, err := s.messages.awaitReply(ctx, rec, cursor)
+	if err == errReplyTimeout {
+		return nil, status.Error(codes.DeadlineExceeded, "Timeout waiting for reply")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Error reading reply: %v", err)
+	}
+	if err := s.messages.schemas.validateOutput(ctx, agent, rec.Reply.Payload); err != nil {
+		return nil, schemaError(err, codes.Internal, "Agent reply does not match its output schema", "Error loading output schema")
+	}
+	return messageResponse(rec, replyID), nil
+}
+
+// StreamReplies sends every entry an agent publishes to its outbox until
+// the caller cancels the call
+func (s *AgentService) StreamReplies(req *agentrpc.StreamRepliesRequest, stream agentrpc.AgentService_StreamRepliesServer) error {
+	ctx := stream.Context()
+	agent, err := s.resolve(ctx, req.GetAgent(), "get")
+	if err != nil {
+		return err
+	}
+
+	cursor := req.GetAfterId()
+	if cursor == "" {
+		cursor = "$"
+	}
+	log.Info("Streaming agent outbox over gRPC", "agent", agent.Name, "from", cursor)
+
+	err = s.messages.followOutbox(ctx, agent, cursor, req.GetCorrelationId(), func(id string, env *wire.Envelope) error {
+		return stream.Send(&agentrpc.Reply{Id: id, Message: toProtoEnvelope(env)})
+	}, func(id string, env *wire.Envelope, violation error) error {
+		// A Reply cannot carry an error, so the call ends; callers can resume
+		// after the rejected entry
+		return schemaError(violation, codes.Internal,
+			fmt.Sprintf("Agent reply %s does not match its output schema", id), "Error loading output schema")
+	}, func() error {
+		// HTTP/2 keeps the connection alive on its own
+		return nil
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Errorf(codes.Internal, "Error reading outbox: %v", err)
+	}
+	return nil
+}
+
+// ListAgents lists agents, optionally of a single namespace or type
+func (s *AgentService) ListAgents(ctx context.Context, req *agentrpc.ListAgentsRequest) (*agentrpc.ListAgentsResponse, error) {
+	namespace := req.GetNamespace()
+	if namespace == "" && req.GetType() != "" {
+		namespace = agentTypeNamespace(req.GetType())
+	}
+	if err := s.authorize(ctx, auth.AgentAttributes("list", namespace, "", "")); err != nil {
+		return nil, err
+	}
+
+	opts := []client.ListOption{}
+	if namespace != "" {
+		opts = append(opts, client.InNamespace(namespace))
+	}
+	var agents agentsv1alpha1.AgentList
+	if err := s.messages.client.List(ctx, &agents, opts...); err != nil {
+		return nil, status.Errorf(codes.Internal, "Error listing agents: %v", err)
+	}
+
+	resp := &agentrpc.ListAgentsResponse{}
+	for _, agent := range agents.Items {
+		if req.GetType() != "" && agent.Spec.Type != req.GetType() {
+			continue
+		}
+		resp.Agents = append(resp.Agents, &agentrpc.Agent{
+			Namespace:    agent.Namespace,
+			Name:         agent.Name,
+			Type:         agent.Spec.Type,
+			Image:        agent.Spec.Image,
+			Phase:        agent.Status.Phase,
+			Message:      agent.Status.Message,
+			RestartCount: int32(agent.Status.RestartCount),
+		})
+	}
+	return resp, nil
+}
+
+// resolve authorizes verb on the messages of the referenced agent, like the
+// HTTP API does for the equivalent path, and looks the agent up
+func (s *AgentService) resolve(ctx context.Context, ref *agentrpc.AgentRef, verb string) (*agentsv1alpha1.Agent, error) {
+	if ref.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "agent.name is required")
+	}
+	if s.messages.redis == nil {
+		return nil, status.Error(codes.Unavailable, "Valkey connection not available")
+	}
+
+	namespace := ref.GetNamespace()
+	if namespace == "" && ref.GetType() != "" {
+		namespace = agentTypeNamespace(ref.GetType())
+	}
+	if err := s.authorize(ctx, auth.AgentAttributes(verb, namespace, ref.GetName(), auth.SubresourceMessages)); err != nil {
+		return nil, err
+	}
+
+	agent, err := resolveAgent(ctx, s.messages.client, agentRef{
+		Namespace: ref.GetNamespace(),
+		Type:      ref.GetType(),
+		Name:      ref.GetName(),
+	})
+	switch {
+	case err == nil:
+		return agent, nil
+	case apierrors.IsNotFound(err):
+		return nil, status.Errorf(codes.NotFound, "Agent not found: %v", err)
+	case apierrors.IsConflict(err):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return nil, status.Errorf(codes.Internal, "Error looking up agent: %v", err)
+	}
+}
+
+func (s *AgentService) authorize(ctx context.Context, attrs *authorizationv1.ResourceAttributes) error {
+	if s.authz == nil {
+		return nil
+	}
+	return s.authz.Authorize(ctx, attrs)
+}
+
+// callerOf identifies who made a call: the authenticated user if there is
+// one, otherwise the optional x-user-id metadata
+func callerOf(ctx context.Context) string {
+	if user := auth.UserFrom(ctx); user != nil {
+		return user.Name
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-user-id"); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// callerKeyOf identifies the caller of a gRPC call for rate limiting, like
+// callerKey does for HTTP requests
+func callerKeyOf(ctx context.Context) string {
+	if caller := callerOf(ctx); caller != "" {
+		return "user:" + caller
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "addr:" + host
+	}
+	return ""
+}
+
+// retryError returns a status error that tells the caller when to retry
+func retryError(code codes.Code, msg string, delay time.Duration) error {
+	st, err := status.New(code, msg).WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+	if err != nil {
+		return status.Error(code, msg)
+	}
+	return st.Err()
+}
+
+// schemaError converts an error validating a payload into a status error.
+// Schema violations are reported with code and the offending fields.
+func schemaError(err error, code codes.Code, violated, failed string) error {
+	var violation *schemaViolation
+	if !errors.As(err, &violation) {
+		return status.Errorf(codes.Internal, "%s: %v", failed, err)
+	}
+	details := &errdetails.BadRequest{}
+	for _, fe := range violation.errors {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       "payload" + fe.Field,
+			Description: fe.Message,
+		})
+	}
+	st, serr := status.New(code, fmt.Sprintf("%s: %v", violated, err)).WithDetails(details)
+	if serr != nil {
+		return status.Errorf(code, "%s: %v", violated, err)
+	}
+	return st.Err()
+}
+
+func messageResponse(rec *messageRecord, replyID string) *agentrpc.SendMessageResponse {
+	return &agentrpc.SendMessageResponse{
+		Id:       rec.ID,
+		Status:   toProtoStatus(rec.Status),
+		InboxId:  rec.InboxID,
+		Deadline: timestamppb.New(rec.Deadline),
+		Reply:    toProtoEnvelope(rec.Reply),
+		ReplyId:  replyID,
+	}
+}
+
+func toProtoStatus(s MessageStatus) agentrpc.MessageStatus {
+	switch s {
+	case StatusQueued:
+		return agentrpc.MessageStatus_MESSAGE_STATUS_QUEUED
+	case StatusDelivered:
+		return agentrpc.MessageStatus_MESSAGE_STATUS_DELIVERED
+	case StatusReplied:
+		return agentrpc.MessageStatus_MESSAGE_STATUS_REPLIED
+	case StatusTimedOut:
+		return agentrpc.MessageStatus_MESSAGE_STATUS_TIMED_OUT
+	default:
+		return agentrpc.MessageStatus_MESSAGE_STATUS_UNSPECIFIED
+	}
+}
+
+func toProtoEnvelope(env *wire.Envelope) *agentrpc.Envelope {
+	if env == nil {
+		return nil
+	}
+	out := &agentrpc.Envelope{
+		Id:            env.ID,
+		Type:          env.Type,
+		Sender:        env.Sender,
+		ReplyTo:       env.ReplyTo,
+		CorrelationId: env.CorrelationID,
+		ContentType:   env.ContentType,
+	}
+	if len(env.Payload) > 0 {
+		out.Payload = jsonValue(env.Payload)
+	}
+	return out
+}
+
+// jsonValue converts a JSON document to a protobuf Value, falling back to
+// the raw text as a string value if it is not valid JSON
+func jsonValue(data json.RawMessage) *structpb.Value {
+	v := &structpb.Value{}
+	if err := protojson.Unmarshal(data, v); err != nil {
+		log.Error(err, "Payload is not valid JSON, passing it as a string")
+		return structpb.NewStringValue(string(data))
+	}
+	return v
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+	"github.com/Algoluna/agent-operator/pkg/wire"
+)
+
+// inboxFullRetryAfter is how long callers are asked to wait before sending
+// again to an agent whose inbox backlog is full
+const inboxFullRetryAfter = 10 * time.Second
+
+// inboxFullError is returned when an agent's inbox holds as many unconsumed
+// messages as it may
+type inboxFullError struct {
+	agent   string
+	backlog int64
+	limit   int64
+}
+
+func (e *inboxFullError) Error() string {
+	return fmt.Sprintf("inbox of agent %s is full: %d of %d messages not yet consumed", e.agent, e.backlog, e.limit)
+}
+
+// inboxLimits returns the inbox limits of an agent's spec.messaging
+func inboxLimits(agent *agentsv1alpha1.Agent) wire.InboxLimits {
+	m := agent.Spec.Messaging
+	if m == nil {
+		return wire.InboxLimits{}
+	}
+	return wire.InboxLimits{
+		MaxLength:  m.MaxInboxLength,
+		TrimPolicy: string(m.InboxTrimPolicy),
+		Retention:  time.Duration(m.InboxRetentionSeconds) * time.Second,
+		MaxBacklog: m.MaxBacklog,
+	}
+}
+
+// inboxAddArgs returns the arguments to add values to an agent's inbox,
+// approximately trimming it to the agent's limits
+func inboxAddArgs(agent *agentsv1alpha1.Agent, values map[string]interface{}) *redis.XAddArgs {
+	maxLen, minID := inboxLimits(agent).Trim(time.Now())
+	return &redis.XAddArgs{
+		Stream: wire.InboxKey(agent.Spec.Type, agent.Name),
+		Values: values,
+		MaxLen: maxLen,
+		MinID:  minID,
+		Approx: maxLen > 0 || minID != "",
+	}
+}
+
+// inboxBacklog returns how many messages in an inbox the agent has not yet
+// consumed, i.e. not yet read or read but not yet acknowledged
+func (h *MessageHandler) inboxBacklog(ctx context.Context, stream string) (int64, error) {
+	groups, err := h.redis.XInfoGroups(ctx, stream).Result()
+	if wire.IsMissingStream(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	info, err := h.redis.XInfoStream(ctx, stream).Result()
+	if err != nil {
+		return 0, err
+	}
+	states := make([]wire.InboxGroupState, 0, len(groups))
+	for _, group := range groups {
+		states = append(states, wire.InboxGroupState{
+			Name:            group.Name,
+			Lag:             group.Lag,
+			Pending:         group.Pending,
+			LastDeliveredID: group.LastDeliveredID,
+			EntriesRead:     group.EntriesRead,
+		})
+	}
+	return wire.InboxBacklog(states, wire.InboxStreamState{
+		Length:          info.Length,
+		LastGeneratedID: info.LastGeneratedID,
+		EntriesAdded:    info.EntriesAdded,
+	}), nil
+}
+
+// checkBacklog returns an inboxFullError if the agent's inbox may not take
+// another message
+func (h *MessageHandler) checkBacklog(ctx context.Context, agent *agentsv1alpha1.Agent) error {
+	limit := inboxLimits(agent).BacklogLimit()
+	if limit <= 0 {
+		return nil
+	}
+	backlog, err := h.inboxBacklog(ctx, wire.InboxKey(agent.Spec.Type, agent.Name))
+	if err != nil {
+		return fmt.Errorf("failed to read inbox backlog: %w", err)
+	}
+	if backlog >= limit {
+		return &inboxFullError{agent: agent.Name, backlog: backlog, limit: limit}
+	}
+	return nil
+}
+
+// writeSendError reports an error sending a message over HTTP, replying with
+// 503 and a Retry-After header if the agent's inbox is full
+func writeSendError(w http.ResponseWriter, err error) {
+	var full *inboxFullError
+	if errors.As(err, &full) {
+		log.Info("Refused message to full inbox", "agent", full.agent, "backlog", full.backlog, "limit", full.limit)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(inboxFullRetryAfter)))
+		http.Error(w, fmt.Sprintf("Agent is not keeping up: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, fmt.Sprintf("Failed to send message: %v", err), http.StatusInternalServerError)
+}
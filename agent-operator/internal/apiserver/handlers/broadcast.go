@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+	"github.com/Algoluna/agent-operator/pkg/wire"
+)
+
+// maxBroadcastBodySize limits the size of broadcast requests
+const maxBroadcastBodySize = 1 << 20
+
+// statusFailed marks agents a broadcast could not be sent to
+const statusFailed MessageStatus = "failed"
+
+// broadcastRequest is the body of a broadcast
+type broadcastRequest struct {
+	Payload json.RawMessage `json:"payload"`
+	// Timeout is how long to wait for replies, in seconds
+	Timeout int `json:"timeout,omitempty"`
+	// Selector is a label selector the agents must match
+	Selector string `json:"selector,omitempty"`
+	// Quorum is how many replies to wait for, by default one per agent
+	Quorum int `json:"quorum,omitempty"`
+}
+
+// broadcastResult is the outcome of a broadcast for a single agent
+type broadcastResult struct {
+	Agent   string         `json:"agent"`
+	ID      string         `json:"id,omitempty"`
+	Status  MessageStatus  `json:"status"`
+	ReplyID string         `json:"replyId,omitempty"`
+	Reply   *wire.Envelope `json:"reply,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// broadcastResponse aggregates the replies to a broadcast
+type broadcastResponse struct {
+	ID            string            `json:"id"`
+	Type          string            `json:"type"`
+	Agents        int               `json:"agents"`
+	Quorum        int               `json:"quorum"`
+	Replied       int               `json:"replied"`
+	QuorumReached bool              `json:"quorumReached"`
+	Results       []broadcastResult `json:"results"`
+}
+
+// BroadcastHandler handles API requests that send a message to every agent
+// of a type:
+//
+//	POST /api/v1/types/{type}/broadcast
+//
+// Each agent gets its own message, which is sent, limited and tracked like
+// one sent to /agents/{name}/messages. The response aggregates the replies
+// once a quorum of agents has answered or the timeout passes; messages still
+// unanswered by then can be followed through their status.
+type BroadcastHandler struct {
+	messages *MessageHandler
+}
+
+// NewBroadcastHandler creates a new broadcast handler sending messages
+// through the given message handler, sharing its connections and rate limits
+func NewBroadcastHandler(messages *MessageHandler) *BroadcastHandler {
+	return &BroadcastHandler{
+		messages: messages,
+	}
+}
+
+// ServeHTTP handles HTTP requests
+func (h *BroadcastHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.messages.redis == nil {
+		http.Error(w, "Valkey connection not available", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+	agentType := r.PathValue("type")
+
+	var req broadcastRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBroadcastBodySize)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	selector, err := labels.Parse(req.Selector)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid selector: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Quorum < 0 {
+		http.Error(w, "quorum must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	agents, err := h.listAgents(ctx, agentType, selector)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error listing agents: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if len(agents) == 0 {
+		http.Error(w, fmt.Sprintf("No agents of type %q match", agentType), http.StatusNotFound)
+		return
+	}
+	quorum := req.Quorum
+	if quorum == 0 {
+		quorum = len(agents)
+	}
+	if quorum > len(agents) {
+		http.Error(w, fmt.Sprintf("quorum %d exceeds the %d matching agents", quorum, len(agents)), http.StatusBadRequest)
+		return
+	}
+
+	timeout := defaultTimeout
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	resp := &broadcastResponse{
+		ID:      uuid.NewString(),
+		Type:    agentType,
+		Agents:  len(agents),
+		Quorum:  quorum,
+		Results: make([]broadcastResult, len(agents)),
+	}
+	log.Info("Broadcasting message", "type", agentType, "broadcastID", resp.ID, "agents", len(agents), "quorum", quorum, "user", senderOf(r))
+
+	records := make([]*messageRecord, len(agents))
+	cursors := make([]string, len(agents))
+	for i := range agents {
+		agent := &agents[i]
+		resp.Results[i] = broadcastResult{Agent: agent.Name, Status: statusFailed}
+		if err := h.send(r, agent, req.Payload, timeout, records, cursors, i); err != nil {
+			resp.Results[i].Error = err.Error()
+		}
+	}
+
+	h.awaitQuorum(ctx, agents, records, cursors, resp)
+
+	code := http.StatusOK
+	if !resp.QuorumReached {
+		code = http.StatusGatewayTimeout
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// listAgents returns the agents of a type that match selector, sorted by name
+func (h *BroadcastHandler) listAgents(ctx context.Context, agentType string, selector labels.Selector) ([]agentsv1alpha1.Agent, error) {
+	var list agentsv1alpha1.AgentList
+	if err := h.messages.client.List(ctx, &list,
+		client.InNamespace(agentTypeNamespace(agentType)),
+		client.MatchingLabelsSelector{Selector: selector},
+	); err != nil {
+		return nil, err
+	}
+	agents := make([]agentsv1alpha1.Agent, 0, len(list.Items))
+	for _, agent := range list.Items {
+		if agent.Spec.Type == agentType {
+			agents = append(agents, agent)
+		}
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].Name < agents[j].Name })
+	return agents, nil
+}
+
+// send applies the checks of a single message to an agent and adds the
+// payload to its inbox, storing the record and reply stream cursor at i
+func (h *BroadcastHandler) send(r *http.Request, agent *agentsv1alpha1.Agent, payload json.RawMessage, timeout time.Duration,
+	records []*messageRecord, cursors []string, i int) error {
+	ctx := r.Context()
+	if delay, ok := h.messages.limiter.reserve(callerKey(r), agent); !ok {
+		return fmt.Errorf("rate limit exceeded, retry in %ds", retryAfterSeconds(delay))
+	}
+	if err := h.messages.schemas.validateInput(ctx, agent, payload); err != nil {
+		return err
+	}
+	rec, cursor, err := h.messages.enqueueMessage(ctx, agent, uuid.NewString(), senderOf(r), payload, timeout)
+	if err != nil {
+		return err
+	}
+	records[i] = rec
+	cursors[i] = cursor
+	return nil
+}
+
+// awaitQuorum waits for the agents to reply until a quorum has or no longer
+// can be reached, then fills in the results of the response
+func (h *BroadcastHandler) awaitQuorum(ctx context.Context, agents []agentsv1alpha1.Agent, records []*messageRecord, cursors []string, resp *broadcastResponse) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type outcome struct {
+		i       int
+		replyID string
+		err     error
+	}
+	outcomes := make(chan outcome)
+	var wg sync.WaitGroup
+	waiting := 0
+	for i, rec := range records {
+		if rec == nil {
+			continue
+		}
+		waiting++
+		wg.Add(1)
+		go func(i int, rec *messageRecord) {
+			defer wg.Done()
+			replyID, err := h.messages.awaitReply(ctx, rec, cursors[i])
+			select {
+			case outcomes <- outcome{i: i, replyID: replyID, err: err}:
+			case <-ctx.Done():
+			}
+		}(i, rec)
+	}
+
+	// Once the quorum is settled the remaining waits are abandoned; their
+	// messages stay queued and can be followed through their status
+	for waiting > 0 && resp.Replied < resp.Quorum && resp.Replied+waiting >= resp.Quorum {
+		o := <-outcomes
+		waiting--
+		result := &resp.Results[o.i]
+		result.ReplyID = o.replyID
+		if o.err != nil {
+			if !errors.Is(o.err, errReplyTimeout) {
+				result.Error = fmt.Sprintf("error reading reply: %v", o.err)
+			}
+			continue
+		}
+		if err := h.messages.schemas.validateOutput(ctx, &agents[o.i], records[o.i].Reply.Payload); err != nil {
+			result.Error = fmt.Sprintf("reply does not match the output schema: %v", err)
+			continue
+		}
+		resp.Replied++
+	}
+	cancel()
+	wg.Wait()
+
+	resp.QuorumReached = resp.Replied >= resp.Quorum
+	for i, rec := range records {
+		if rec == nil {
+			continue
+		}
+		result := &resp.Results[i]
+		result.ID = rec.ID
+		result.Status = rec.Status
+		result.Reply = rec.Reply
+	}
+}
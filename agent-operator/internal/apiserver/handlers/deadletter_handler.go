@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Algoluna/agent-operator/pkg/wire"
+)
+
+// defaultDeadLetterLimit is how many dead letters are listed by default
+const defaultDeadLetterLimit = 50
+
+// DeadLetterHandler handles API requests for the dead-letter stream of an
+// agent type
+type DeadLetterHandler struct {
+	client client.Client
+	scheme *runtime.Scheme
+	redis  *redis.Client
+}
+
+// NewDeadLetterHandler creates a new dead-letter handler
+func NewDeadLetterHandler(client client.Client, scheme *runtime.Scheme) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		client: client,
+		scheme: scheme,
+		redis:  newValkeyClient(),
+	}
+}
+
+// deadLetterEntry is a dead letter as returned by the API
+type deadLetterEntry struct {
+	ID string `json:"id"`
+	*wire.DeadLetter
+	// Error and Values are set instead of the dead letter if the entry
+	// could not be decoded
+	Error  string                 `json:"error,omitempty"`
+	Values map[string]interface{} `json:"values,omitempty"`
+}
+
+// ServeHTTP handles HTTP requests
+func (h *DeadLetterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.redis == nil {
+		http.Error(w, "Valkey connection not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Expected format: /api/v1/types/{agent-type}/deadletters[/{id}[/replay]]
+	pathParts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	if len(pathParts) < 6 || pathParts[1] != "api" || pathParts[2] != "v1" || pathParts[3] != "types" || pathParts[5] != "deadletters" {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+
+	agentType := pathParts[4]
+	if agentType == "" {
+		http.Error(w, "Agent type required", http.StatusBadRequest)
+		return
+	}
+
+	// Malformed IDs would only be refused by Valkey
+	if len(pathParts) > 6 && !isStreamID(pathParts[6]) {
+		http.Error(w, fmt.Sprintf("Invalid dead letter ID %q, expected <ms>-<seq>", pathParts[6]), http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(pathParts) == 6 && r.Method == http.MethodGet:
+		h.handleListDeadLetters(w, r, agentType)
+	case len(pathParts) == 6 && r.Method == http.MethodDelete:
+		h.handlePurgeDeadLetters(w, r, agentType)
+	case len(pathParts) == 7 && r.Method == http.MethodGet:
+		h.handleGetDeadLetter(w, r, agentType, pathParts[6])
+	case len(pathParts) == 7 && r.Method == http.MethodDelete:
+		h.handleDeleteDeadLetter(w, r, agentType, pathParts[6])
+	case len(pathParts) == 8 && pathParts[7] == "replay" && r.Method == http.MethodPost:
+		h.handleReplayDeadLetter(w, r, agentType, pathParts[6])
+	case len(pathParts) <= 8:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.Error(w, "Invalid endpoint", http.StatusBadRequest)
+	}
+}
+
+// handleListDeadLetters lists the newest dead letters of an agent type,
+// optionally only those of a single agent
+func (h *DeadLetterHandler) handleListDeadLetters(w http.ResponseWriter, r *http.Request, agentType string) {
+	ctx := r.Context()
+	stream := wire.DeadLetterKey(agentType)
+
+	limit := defaultDeadLetterLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		fmt.Sscanf(limitStr, "%d", &limit)
+		if limit <= 0 {
+			limit = defaultDeadLetterLimit
+		}
+	}
+	agentName := r.URL.Query().Get("agent")
+
+	length, err := h.redis.XLen(ctx, stream).Result()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error reading dead letters: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Page through the stream newest first until enough entries match
+	entries := make([]deadLetterEntry, 0)
+	end := "+"
+	for len(entries) < limit {
+		res, err := h.redis.XRevRangeN(ctx, stream, end, "-", int64(limit)).Result()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error reading dead letters: %v", err), http.StatusInternalServerError)
+			return
+		}
+		for _, msg := range res {
+			entry := newDeadLetterEntry(msg)
+			if agentName != "" && (entry.DeadLetter == nil || entry.Agent != agentName) {
+				continue
+			}
+			if len(entries) < limit {
+				entries = append(entries, entry)
+			}
+		}
+		if len(res) < limit {
+			break
+		}
+		end = "(" + res[len(res)-1].ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"stream":      stream,
+		"length":      length,
+		"deadLetters": entries,
+	})
+}
+
+// handleGetDeadLetter returns a single dead letter
+func (h *DeadLetterHandler) handleGetDeadLetter(w http.ResponseWriter, r *http.Request, agentType, id string) {
+	msg, ok := h.loadDeadLetter(w, r, agentType, id)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newDeadLetterEntry(*msg))
+}
+
+// handleReplayDeadLetter puts a dead letter back into the inbox it was taken
+// from and removes it from the dead-letter stream
+func (h *DeadLetterHandler) handleReplayDeadLetter(w http.ResponseWriter, r *http.Request, agentType, id string) {
+	ctx := r.Context()
+
+	msg, ok := h.loadDeadLetter(w, r, agentType, id)
+	if !ok {
+		return
+	}
+	dl, err := wire.DecodeDeadLetter(msg.Values)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Cannot replay undecodable dead letter: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Only ever replay into the inbox of the agent the message was taken
+	// from, and only while that agent exists
+	if dl.Agent == "" {
+		http.Error(w, "Dead letter does not name its agent", http.StatusUnprocessableEntity)
+		return
+	}
+	inbox := wire.InboxKey(agentType, dl.Agent)
+	if dl.Source != "" && dl.Source != inbox {
+		http.Error(w, fmt.Sprintf("Dead letter has an invalid source %q", dl.Source), http.StatusUnprocessableEntity)
+		return
+	}
+	agent, err := resolveAgent(ctx, h.client, agentRef{Type: agentType, Name: dl.Agent})
+	if apierrors.IsNotFound(err) {
+		http.Error(w, fmt.Sprintf("Agent %s of the dead letter does not exist", dl.Agent), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+
+	values, err := wire.Encode(dl.Envelope)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error encoding message: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Replays trim the inbox like any other message but are not refused when
+	// its backlog is full, as an operator asked for them
+	args := inboxAddArgs(agent, values)
+
+	var added *redis.StringCmd
+	_, err = h.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		added = pipe.XAdd(ctx, args)
+		pipe.XDel(ctx, wire.DeadLetterKey(agentType), id)
+		return nil
+	})
+	if err != nil {
+		log.Error(err, "Failed to replay dead letter", "type", agentType, "id", id)
+		http.Error(w, fmt.Sprintf("Error replaying dead letter: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Info("Replayed dead letter", "type", agentType, "id", id, "inbox", inbox, "inboxId", added.Val())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      id,
+		"inbox":   inbox,
+		"inboxId": added.Val(),
+	})
+}
+
+// handleDeleteDeadLetter removes a single dead letter
+func (h *DeadLetterHandler) handleDeleteDeadLetter(w http.ResponseWriter, r *http.Request, agentType, id string) {
+	deleted, err := h.redis.XDel(r.Context(), wire.DeadLetterKey(agentType), id).Result()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error deleting dead letter: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePurgeDeadLetters removes every dead letter of an agent type
+func (h *DeadLetterHandler) handlePurgeDeadLetters(w http.ResponseWriter, r *http.Request, agentType string) {
+	ctx := r.Context()
+	stream := wire.DeadLetterKey(agentType)
+
+	var length *redis.IntCmd
+	_, err := h.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		length = pipe.XLen(ctx, stream)
+		pipe.Del(ctx, stream)
+		return nil
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error purging dead letters: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Info("Purged dead letters", "type", agentType, "count", length.Val())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"purged": length.Val(),
+	})
+}
+
+// loadDeadLetter reads a single entry of the dead-letter stream, writing an
+// error response if it cannot be found
+func (h *DeadLetterHandler) loadDeadLetter(w http.ResponseWriter, r *http.Request, agentType, id string) (*redis.XMessage, bool) {
+	res, err := h.redis.XRangeN(r.Context(), wire.DeadLetterKey(agentType), id, id, 1).Result()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error reading dead letter: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	if len(res) == 0 {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return nil, false
+	}
+	return &res[0], true
+}
+
+// isStreamID reports whether id is a complete stream entry ID, i.e. a
+// millisecond timestamp and a sequence number separated by a dash
+func isStreamID(id string) bool {
+	ms, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return false
+	}
+	_, msErr := strconv.ParseUint(ms, 10, 64)
+	_, seqErr := strconv.ParseUint(seq, 10, 64)
+	return msErr == nil && seqErr == nil
+}
+
+func newDeadLetterEntry(msg redis.XMessage) deadLetterEntry {
+	dl, err := wire.DecodeDeadLetter(msg.Values)
+	if err != nil {
+		return deadLetterEntry{ID: msg.ID, Error: err.Error(), Values: msg.Values}
+	}
+	return deadLetterEntry{ID: msg.ID, DeadLetter: dl}
+}
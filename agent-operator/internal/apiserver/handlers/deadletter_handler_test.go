@@ -0,0 +1,24 @@
+package handlers
+
+import "testing"
+
+func TestIsStreamID(t *testing.T) {
+	for id, want := range map[string]bool{
+		"1700000000000-0":        true,
+		"0-0":                    true,
+		"18446744073709551615-1": true,
+		"1700000000000":          false,
+		"1700000000000-":         false,
+		"-0":                     false,
+		"1-2-3":                  false,
+		"+1-0":                   false,
+		"1--1":                   false,
+		"abc-0":                  false,
+		"$":                      false,
+		"":                       false,
+	} {
+		if got := isStreamID(id); got != want {
+			t.Errorf("isStreamID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
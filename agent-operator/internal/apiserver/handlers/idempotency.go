@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+	"github.com/Algoluna/agent-operator/pkg/wire"
+)
+
+const (
+	// idempotencyHeader carries a client-chosen key that makes retrying a
+	// message submission safe
+	idempotencyHeader = "Idempotency-Key"
+
+	// idempotentReplayedHeader is set on responses to retries, which return
+	// the result of the first attempt
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	// maxIdempotencyKeyLength limits the length of Idempotency-Key headers
+	maxIdempotencyKeyLength = 255
+)
+
+// idempotencyEntry is what is remembered about a request with an
+// Idempotency-Key: the message it sent and a digest of the request, so the
+// key cannot be reused for a different request
+type idempotencyEntry struct {
+	MessageID   string `json:"messageId"`
+	RequestHash string `json:"requestHash"`
+}
+
+// idempotentRequest is a message submission that carries an Idempotency-Key
+type idempotentRequest struct {
+	redis       *redis.Client
+	key         string
+	requestHash string
+}
+
+// newIdempotentRequest returns the idempotency key of a message submission,
+// or nil if it has none. Keys are scoped to the agent and the caller, so
+// callers cannot see each other's results by guessing keys.
+func (h *MessageHandler) newIdempotentRequest(r *http.Request, agent *agentsv1alpha1.Agent, payload json.RawMessage, timeout time.Duration, async bool) (*idempotentRequest, error) {
+	header := r.Header.Get(idempotencyHeader)
+	if header == "" {
+		return nil, nil
+	}
+	if len(header) > maxIdempotencyKeyLength {
+		return nil, fmt.Errorf("%s must not be longer than %d characters", idempotencyHeader, maxIdempotencyKeyLength)
+	}
+
+	digest := sha256.Sum256([]byte(callerKey(r) + "\x00" + header))
+	request := sha256.Sum256([]byte(strconv.FormatBool(async) + "\x00" + timeout.String() + "\x00" + string(payload)))
+	return &idempotentRequest{
+		redis:       h.redis,
+		key:         wire.IdempotencyKey(agent.Spec.Type, agent.Name, hex.EncodeToString(digest[:])),
+		requestHash: hex.EncodeToString(request[:]),
+	}, nil
+}
+
+// lookup returns what is remembered about the key, or nil if it is new
+func (req *idempotentRequest) lookup(ctx context.Context) (*idempotencyEntry, error) {
+	data, err := req.redis.Get(ctx, req.key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry idempotencyEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode idempotency key: %w", err)
+	}
+	return &entry, nil
+}
+
+// claim remembers that the key sent the message with the given ID for ttl,
+// which should match the retention of the message's status record. If
+// another request claimed the key first, it returns what that request
+// stored instead.
+func (req *idempotentRequest) claim(ctx context.Context, messageID string, ttl time.Duration) (*idempotencyEntry, error) {
+	data, err := json.Marshal(idempotencyEntry{MessageID: messageID, RequestHash: req.requestHash})
+	if err != nil {
+		return nil, err
+	}
+	ok, err := req.redis.SetNX(ctx, req.key, data, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return nil, nil
+	}
+	entry, err := req.lookup(ctx)
+	if err == nil && entry == nil {
+		err = fmt.Errorf("idempotency key expired while claiming it")
+	}
+	return entry, err
+}
+
+// release forgets the key after its request failed to send a message
+func (req *idempotentRequest) release(ctx context.Context) {
+	if err := req.redis.Del(ctx, req.key).Err(); err != nil {
+		log.Error(err, "Failed to release idempotency key", "key", req.key)
+	}
+}
+
+// replayIdempotent answers a retry of a message submission with the result
+// of the message the first attempt sent, waiting for its reply if needed
+func (h *MessageHandler) replayIdempotent(w http.ResponseWriter, r *http.Request, agent *agentsv1alpha1.Agent, req *idempotentRequest, entry *idempotencyEntry, async bool) {
+	ctx := r.Context()
+
+	if entry.RequestHash != req.requestHash {
+		http.Error(w, fmt.Sprintf("%s was already used for a different request", idempotencyHeader), http.StatusUnprocessableEntity)
+		return
+	}
+
+	rec, err := h.loadRecord(ctx, agent, entry.MessageID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error reading message status: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if rec == nil {
+		// The first attempt has claimed the key but not yet queued its message
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, fmt.Sprintf("A request with this %s is still being processed", idempotencyHeader), http.StatusConflict)
+		return
+	}
+	if err := h.refreshRecord(ctx, rec); err != nil {
+		http.Error(w, fmt.Sprintf("Error updating message status: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Info("Replaying idempotent message submission", "agent", agent.Name, "messageID", rec.ID)
+
+	// The reply, if any, may be anywhere in the reply stream
+	w.Header().Set(idempotentReplayedHeader, "true")
+	h.writeSendResult(w, r, agent, rec, "0-0", async)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/redis/go-redis/v9"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+	"github.com/Algoluna/agent-operator/pkg/wire"
+)
+
+// inboxConsumer describes a consumer of an agent's inbox group
+type inboxConsumer struct {
+	Name    string `json:"name"`
+	Pending int64  `json:"pending"`
+	IdleMs  int64  `json:"idleMs"`
+}
+
+// inboxInfo describes the state of an agent's inbox stream
+type inboxInfo struct {
+	Stream          string          `json:"stream"`
+	Group           string          `json:"group"`
+	Length          int64           `json:"length"`
+	Pending         int64           `json:"pending"`
+	Lag             int64           `json:"lag"`
+	LastDeliveredID string          `json:"lastDeliveredId,omitempty"`
+	Consumers       []inboxConsumer `json:"consumers"`
+}
+
+// handleGetInbox reports the length of an agent's inbox and how many of its
+// messages are pending, i.e. delivered to a consumer but not yet acknowledged
+func (h *MessageHandler) handleGetInbox(w http.ResponseWriter, r *http.Request, agent *agentsv1alpha1.Agent) {
+	ctx := r.Context()
+
+	info := inboxInfo{
+		Stream:    wire.InboxKey(agent.Spec.Type, agent.Name),
+		Group:     wire.InboxGroup,
+		Consumers: []inboxConsumer{},
+	}
+
+	length, err := h.redis.XLen(ctx, info.Stream).Result()
+	if err != nil && err != redis.Nil {
+		http.Error(w, fmt.Sprintf("Error reading inbox: %v", err), http.StatusInternalServerError)
+		return
+	}
+	info.Length = length
+
+	groups, err := h.redis.XInfoGroups(ctx, info.Stream).Result()
+	if err != nil && !wire.IsMissingStream(err) {
+		http.Error(w, fmt.Sprintf("Error reading inbox groups: %v", err), http.StatusInternalServerError)
+		return
+	}
+	for _, group := range groups {
+		if group.Name != wire.InboxGroup {
+			continue
+		}
+		info.Pending = group.Pending
+		info.Lag = group.Lag
+		info.LastDeliveredID = group.LastDeliveredID
+
+		consumers, err := h.redis.XInfoConsumers(ctx, info.Stream, wire.InboxGroup).Result()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error reading inbox consumers: %v", err), http.StatusInternalServerError)
+			return
+		}
+		for _, c := range consumers {
+			info.Consumers = append(info.Consumers, inboxConsumer{
+				Name:    c.Name,
+				Pending: c.Pending,
+				IdleMs:  c.Idle.Milliseconds(),
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
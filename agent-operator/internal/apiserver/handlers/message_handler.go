@@ -1,33 +1,62 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
-	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+	"github.com/Algoluna/agent-operator/internal/apiserver/auth"
+	"github.com/Algoluna/agent-operator/pkg/wire"
 )
 
 var log = logf.Log.WithName("message-handler")
 
+const (
+	// defaultTimeout is how long a synchronous request waits for a reply
+	defaultTimeout = 30 * time.Second
+
+	// defaultAsyncTimeout is how long an asynchronous request may take to be
+	// answered before it is reported as timed out
+	defaultAsyncTimeout = 15 * time.Minute
+)
+
 // MessageHandler handles agent messaging API requests
 type MessageHandler struct {
-	client client.Client
-	scheme *runtime.Scheme
-	redis  *redis.Client
+	client     client.Client
+	scheme     *runtime.Scheme
+	redis      *redis.Client
+	messageLog *messageLog
+	limiter    *RateLimiter
+	schemas    *schemaCache
 }
 
-// NewMessageHandler creates a new message handler
-func NewMessageHandler(client client.Client, scheme *runtime.Scheme) *MessageHandler {
+// NewMessageHandler creates a new message handler. apiReader reads objects
+// the manager's cache does not watch, such as schema ConfigMaps. Sending
+// messages is not rate limited if limiter is nil.
+func NewMessageHandler(client client.Client, apiReader client.Reader, scheme *runtime.Scheme, limiter *RateLimiter) *MessageHandler {
+	return &MessageHandler{
+		client:     client,
+		scheme:     scheme,
+		redis:      newValkeyClient(),
+		messageLog: newMessageLog(),
+		limiter:    limiter,
+		schemas:    newSchemaCache(apiReader),
+	}
+}
+
+// newValkeyClient connects to Valkey using the operator's admin credentials
+func newValkeyClient() *redis.Client {
 	// Get Valkey connection info from environment
 	valkeyHost := os.Getenv("VALKEY_HOST")
 	if valkeyHost == "" {
@@ -49,15 +78,10 @@ func NewMessageHandler(client client.Client, scheme *runtime.Scheme) *MessageHan
 	if err != nil {
 		log.Error(err, "Failed to parse Valkey URL", "url", redisURL)
 		// Continue with nil client, will be handled in ServeHTTP
+		return nil
 	}
 
-	rdb := redis.NewClient(redisOpt)
-
-	return &MessageHandler{
-		client: client,
-		scheme: scheme,
-		redis:  rdb,
-	}
+	return redis.NewClient(redisOpt)
 }
 
 // ServeHTTP handles HTTP requests
@@ -67,48 +91,84 @@ func (h *MessageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract agent name from URL path
-	// Expected format: /api/v1/agents/{agent-name}/messages
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) < 5 || pathParts[1] != "api" || pathParts[2] != "v1" || pathParts[3] != "agents" {
+	// Resolve the agent from the URL path, see parseAgentPath
+	ref, rest, ok := parseAgentPath(r.URL.Path)
+	if !ok {
 		http.Error(w, "Invalid path", http.StatusBadRequest)
 		return
 	}
+	agent, err := resolveAgent(r.Context(), h.client, ref)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
 
-	agentName := pathParts[4]
-	if agentName == "" {
-		http.Error(w, "Agent name required", http.StatusBadRequest)
+	// Expected format: .../agents/{agent-name}/history
+	if len(rest) == 1 && rest[0] == "history" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.handleGetHistory(w, r, agent)
+		return
+	}
+
+	// Expected format: .../agents/{agent-name}/inbox
+	if len(rest) == 1 && rest[0] == "inbox" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.handleGetInbox(w, r, agent)
+		return
+	}
+
+	// Expected format: .../agents/{agent-name}/ws
+	if len(rest) == 1 && rest[0] == "ws" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.handleWebSocket(w, r, agent)
 		return
 	}
 
 	// Check if this is a messages endpoint
-	if len(pathParts) < 6 || pathParts[5] != "messages" {
+	if len(rest) < 1 || rest[0] != "messages" {
 		http.Error(w, "Invalid endpoint", http.StatusBadRequest)
 		return
 	}
 
+	// Expected format: .../agents/{agent-name}/messages/stream
+	// or .../agents/{agent-name}/messages/{message-id}
+	if len(rest) > 1 && rest[1] != "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if rest[1] == "stream" {
+			h.handleStreamMessages(w, r, agent)
+		} else {
+			h.handleGetMessageStatus(w, r, agent, rest[1])
+		}
+		return
+	}
+
 	// Handle based on HTTP method
 	switch r.Method {
 	case http.MethodPost:
-		h.handleSendMessage(w, r, agentName)
+		h.handleSendMessage(w, r, agent)
 	case http.MethodGet:
-		h.handleGetMessages(w, r, agentName)
+		h.handleGetMessages(w, r, agent)
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 // handleSendMessage sends a message to an agent
-func (h *MessageHandler) handleSendMessage(w http.ResponseWriter, r *http.Request, agentName string) {
+func (h *MessageHandler) handleSendMessage(w http.ResponseWriter, r *http.Request, agent *agentsv1alpha1.Agent) {
 	ctx := r.Context()
 
-	// Verify agent exists
-	var agent agentsv1alpha1.Agent
-	if err := h.client.Get(ctx, types.NamespacedName{Name: agentName}, &agent); err != nil {
-		http.Error(w, fmt.Sprintf("Agent not found: %v", err), http.StatusNotFound)
-		return
-	}
-
 	// Parse request body
 	var messageReq struct {
 		Payload json.RawMessage `json:"payload"`
@@ -120,62 +180,170 @@ func (h *MessageHandler) handleSendMessage(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Contract violations are reported to the caller rather than the agent
+	if !h.checkInput(w, r, agent, messageReq.Payload) {
+		return
+	}
+
+	// Asynchronous submissions return as soon as the message is queued and
+	// are given more time to be answered, as nobody is holding a connection
+	async := r.URL.Query().Get("async") == "true"
+
 	// Set default timeout
-	timeout := 30
+	timeout := defaultTimeout
+	if async {
+		timeout = defaultAsyncTimeout
+	}
 	if messageReq.Timeout > 0 {
-		timeout = messageReq.Timeout
+		timeout = time.Duration(messageReq.Timeout) * time.Second
 	}
 
-	// Compose stream and reply keys
-	streamKey := fmt.Sprintf("agent:%s:inbox", agentName)
-	replyKey := fmt.Sprintf("agent:%s:reply", agentName)
+	// A retry of a request that carries an Idempotency-Key gets the result
+	// of the message the first attempt sent instead of sending another one
+	idem, err := h.newIdempotentRequest(r, agent, messageReq.Payload, timeout, async)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if idem != nil {
+		entry, err := idem.lookup(ctx)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error reading idempotency key: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if entry != nil {
+			h.replayIdempotent(w, r, agent, idem, entry, async)
+			return
+		}
+	}
 
-	// Send message to agent's inbox stream
-	msgID, err := h.redis.XAdd(ctx, &redis.XAddArgs{
-		Stream: streamKey,
-		Values: map[string]interface{}{
-			"payload": string(messageReq.Payload),
-			"sender":  r.Header.Get("X-User-ID"), // Optional: capture sender ID if provided
-		},
-	}).Result()
+	if !h.allowMessage(w, r, agent) {
+		return
+	}
 
+	id := uuid.NewString()
+	if idem != nil {
+		entry, err := idem.claim(ctx, id, timeout+messageRecordRetention)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error storing idempotency key: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if entry != nil {
+			// A concurrent attempt claimed the key first
+			h.replayIdempotent(w, r, agent, idem, entry, async)
+			return
+		}
+	}
+
+	rec, cursor, err := h.enqueueMessage(ctx, agent, id, senderOf(r), messageReq.Payload, timeout)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to send message: %v", err), http.StatusInternalServerError)
+		if idem != nil {
+			// Nothing was sent, so a retry should try again
+			idem.release(context.WithoutCancel(ctx))
+		}
+		writeSendError(w, err)
 		return
 	}
 
-	log.Info("Message sent to agent", "agent", agentName, "messageID", msgID)
+	h.writeSendResult(w, r, agent, rec, cursor, async)
+}
 
-	// Wait for reply on reply stream
-	deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+// writeSendResult answers a message submission: right away with the
+// message's status if it is async, otherwise with the reply once the agent
+// has answered after cursor
+func (h *MessageHandler) writeSendResult(w http.ResponseWriter, r *http.Request, agent *agentsv1alpha1.Agent, rec *messageRecord, cursor string, async bool) {
+	if async {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", fmt.Sprintf("%s/messages/%s", agentPath(agent), rec.ID))
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(rec)
+		return
+	}
 
-	for {
-		now := time.Now()
-		if now.After(deadline) {
+	replyID := rec.ReplyID
+	switch rec.Status {
+	case StatusReplied:
+	case StatusTimedOut:
+		http.Error(w, "Timeout waiting for reply", http.StatusGatewayTimeout)
+		return
+	default:
+		var err error
+		replyID, err = h.awaitReply(r.Context(), rec, cursor)
+		if err == errReplyTimeout {
 			http.Error(w, "Timeout waiting for reply", http.StatusGatewayTimeout)
 			return
 		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error reading reply: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if !h.checkOutput(w, r, agent, rec) {
+		return
+	}
 
-		// Read latest message from reply stream
+	// Return the reply
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reply":          rec.Reply,
+		"id":             replyID,
+		"correlation_id": rec.ID,
+	})
+}
+
+// errReplyTimeout is returned by awaitReply when the message's deadline
+// passes without a reply
+var errReplyTimeout = errors.New("timeout waiting for reply")
+
+// awaitReply reads the reply stream of a message after cursor until the
+// agent answers or the message's deadline passes. It completes the record
+// and returns the stream entry ID of the reply. The record is reloaded on
+// every poll, as another reader of the reply stream may complete it first
+// and drop the stream along with the reply.
+func (h *MessageHandler) awaitReply(ctx context.Context, rec *messageRecord, cursor string) (string, error) {
+	for {
+		if err := h.reloadRecord(ctx, rec); err != nil {
+			return "", err
+		}
+		if rec.final() {
+			return replyResult(rec)
+		}
+
+		now := time.Now()
+		if now.After(rec.Deadline) {
+			rec.Status = StatusTimedOut
+			if err := h.saveRecord(ctx, rec); err != nil {
+				log.Error(err, "Failed to record message timeout", "id", rec.ID)
+			}
+			return replyResult(rec)
+		}
+
+		// Read replies written since the last one we looked at
 		res, err := h.redis.XRead(ctx, &redis.XReadArgs{
-			Streams: []string{replyKey, "0"},
-			Count:   1,
-			Block:   1000, // 1s
+			Streams: []string{rec.ReplyTo, cursor},
+			Count:   10,
+			Block:   time.Second,
 		}).Result()
 
 		if err != nil && err != redis.Nil {
-			http.Error(w, fmt.Sprintf("Error reading reply: %v", err), http.StatusInternalServerError)
-			return
+			return "", err
 		}
 
-		if len(res) > 0 && len(res[0].Messages) > 0 {
-			// Return the reply
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"reply": res[0].Messages[0].Values,
-				"id":    res[0].Messages[0].ID,
-			})
-			return
+		if len(res) > 0 {
+			for _, msg := range res[0].Messages {
+				cursor = msg.ID
+				if !h.applyReplyEntry(rec, msg) || rec.Status != StatusReplied {
+					// Not the reply to this request (e.g. the stream marker or an ack)
+					continue
+				}
+
+				if err := h.completeRecord(ctx, rec); err != nil {
+					log.Error(err, "Failed to record reply", "id", rec.ID)
+				}
+				return replyResult(rec)
+			}
+			continue
 		}
 
 		// Sleep briefly before next poll
@@ -183,17 +351,125 @@ func (h *MessageHandler) handleSendMessage(w http.ResponseWriter, r *http.Reques
 	}
 }
 
-// handleGetMessages gets messages for an agent
-func (h *MessageHandler) handleGetMessages(w http.ResponseWriter, r *http.Request, agentName string) {
-	ctx := r.Context()
+// replyResult returns what awaitReply returns for a final record
+func replyResult(rec *messageRecord) (string, error) {
+	if rec.Status != StatusReplied {
+		return "", errReplyTimeout
+	}
+	return rec.ReplyID, nil
+}
 
-	// Verify agent exists
-	var agent agentsv1alpha1.Agent
-	if err := h.client.Get(ctx, types.NamespacedName{Name: agentName}, &agent); err != nil {
-		http.Error(w, fmt.Sprintf("Agent not found: %v", err), http.StatusNotFound)
-		return
+// senderOf identifies who sent a request: the authenticated user if there is
+// one, otherwise the optional X-User-ID header
+func senderOf(r *http.Request) string {
+	if user := auth.UserFrom(r.Context()); user != nil {
+		return user.Name
+	}
+	return r.Header.Get("X-User-ID")
+}
+
+// enqueueMessage opens a reply stream for a new message, records its status
+// and adds it to the agent's inbox. It returns the status record together
+// with the reply stream ID from which replies should be read.
+//
+// Every request gets its own correlation ID, a new UUID passed as id, which
+// also names the ephemeral stream the agent writes its reply to and serves
+// as the message ID in the API.
+func (h *MessageHandler) enqueueMessage(ctx context.Context, agent *agentsv1alpha1.Agent, correlationID, sender string, payload json.RawMessage, timeout time.Duration) (*messageRecord, string, error) {
+	replyKey := wire.ReplyKey(agent.Spec.Type, agent.Name, correlationID)
+
+	// Create the reply stream up front so it carries a TTL even if the agent
+	// only answers after we have given up waiting. It lives as long as the
+	// message's record, so the reply can be captured whenever the status is
+	// polled.
+	cursor, err := h.openReplyStream(ctx, replyKey, timeout+messageRecordRetention)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create reply stream: %w", err)
 	}
 
+	rec := &messageRecord{
+		ID:      correlationID,
+		Sender:  sender,
+		ReplyTo: replyKey,
+	}
+	if err := h.addToInbox(ctx, agent, rec, payload, timeout); err != nil {
+		return nil, "", err
+	}
+	return rec, cursor, nil
+}
+
+// addToInbox adds a message to the agent's inbox and records its status.
+// rec must carry the message ID, sender and reply stream; the rest of it is
+// filled in here. It returns an inboxFullError if the agent's backlog is
+// full.
+func (h *MessageHandler) addToInbox(ctx context.Context, agent *agentsv1alpha1.Agent, rec *messageRecord, payload json.RawMessage, timeout time.Duration) error {
+	if err := h.checkBacklog(ctx, agent); err != nil {
+		return err
+	}
+
+	values, err := wire.Encode(&wire.Envelope{
+		ID:            rec.ID,
+		Type:          wire.TypeRequest,
+		Sender:        rec.Sender, // Optional: sender ID if provided
+		ReplyTo:       rec.ReplyTo,
+		CorrelationID: rec.ID,
+		Payload:       payload,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	now := time.Now()
+	rec.Agent = agent.Name
+	rec.AgentType = agent.Spec.Type
+	rec.Status = StatusQueued
+	rec.CreatedAt = now
+	rec.Deadline = now.Add(timeout)
+
+	// Send message to agent's inbox stream
+	msgID, err := h.redis.XAdd(ctx, inboxAddArgs(agent, values)).Result()
+	if err != nil {
+		return err
+	}
+	rec.InboxID = msgID
+
+	if err := h.createRecord(ctx, rec, timeout+messageRecordRetention); err != nil {
+		return fmt.Errorf("failed to record message status: %w", err)
+	}
+	h.trackPending(ctx, rec)
+	h.logRequest(ctx, rec, payload)
+
+	log.Info("Message sent to agent", "agent", agent.Name, "messageID", msgID, "correlationID", rec.ID)
+	return nil
+}
+
+// openReplyStream creates a per-request reply stream that expires after ttl
+// and returns the ID of its marker entry, from which replies should be read
+func (h *MessageHandler) openReplyStream(ctx context.Context, stream string, ttl time.Duration) (string, error) {
+	marker, err := wire.Encode(&wire.Envelope{Type: wire.TypeOpen})
+	if err != nil {
+		return "", err
+	}
+
+	var markerID *redis.StringCmd
+	_, err = h.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		markerID = pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: stream,
+			Values: marker,
+		})
+		pipe.Expire(ctx, stream, ttl)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return markerID.Val(), nil
+}
+
+// handleGetMessages gets messages for an agent
+func (h *MessageHandler) handleGetMessages(w http.ResponseWriter, r *http.Request, agent *agentsv1alpha1.Agent) {
+	ctx := r.Context()
+
 	// Get parameters
 	limit := 10 // Default limit
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
@@ -203,16 +479,12 @@ func (h *MessageHandler) handleGetMessages(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	// Compose reply key - for now, we only read replies
-	replyKey := fmt.Sprintf("agent:%s:reply", agentName)
+	// Every reply an agent sends is also published to its outbox
+	outboxKey := wire.OutboxKey(agent.Spec.Type, agent.Name)
 
-	// Read messages from reply stream
-	res, err := h.redis.XRevRange(ctx, replyKey, "+", "-").Result()
+	// Read messages from the outbox stream
+	res, err := h.redis.XRevRangeN(ctx, outboxKey, "+", "-", int64(limit)).Result()
 
-	// Limit the results if needed
-	if len(res) > limit {
-		res = res[:limit]
-	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error reading messages: %v", err), http.StatusInternalServerError)
 		return
@@ -221,10 +493,14 @@ func (h *MessageHandler) handleGetMessages(w http.ResponseWriter, r *http.Reques
 	// Format as JSON response
 	messages := make([]map[string]interface{}, 0, len(res))
 	for _, msg := range res {
-		messages = append(messages, map[string]interface{}{
+		entry := map[string]interface{}{
 			"id":     msg.ID,
 			"values": msg.Values,
-		})
+		}
+		if env, err := wire.Decode(msg.Values); err == nil {
+			entry["message"] = env
+		}
+		messages = append(messages, entry)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+)
+
+// Message directions recorded in the message log
+const (
+	// DirectionInbound is a message sent to an agent
+	DirectionInbound = "inbound"
+	// DirectionOutbound is a reply sent by an agent
+	DirectionOutbound = "outbound"
+)
+
+// replyLogNamespace derives the log IDs of replies from the ID of the request
+// they answer, so a reply captured more than once is only logged once
+var replyLogNamespace = uuid.MustParse("6f1c1d1e-52a5-4f5e-9b7c-7c4d1a0e3b1a")
+
+// messageLogEntry is a row of public.agent_message_log
+type messageLogEntry struct {
+	ID        string          `json:"id"`
+	AgentID   string          `json:"agentId"`
+	Direction string          `json:"direction"`
+	Sender    string          `json:"sender"`
+	Target    string          `json:"target"`
+	Payload   json.RawMessage `json:"payload"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// historyQuery selects a page of an agent's message log, newest first
+type historyQuery struct {
+	AgentID   string
+	Since     time.Time
+	Until     time.Time
+	Direction string
+	Limit     int
+	Offset    int
+}
+
+// messageLog writes message exchanges to Postgres, as a durable audit trail
+// that does not depend on how long Valkey keeps stream entries
+type messageLog struct {
+	db *sql.DB
+}
+
+// newMessageLog connects to Postgres using the operator's admin credentials.
+// It returns nil if Postgres is not configured.
+func newMessageLog() *messageLog {
+	pgUser := os.Getenv("POSTGRES_USER")
+	pgPassword := os.Getenv("POSTGRES_PASSWORD")
+	pgHost := os.Getenv("POSTGRES_HOST")
+	pgPort := os.Getenv("POSTGRES_PORT")
+	pgDB := os.Getenv("POSTGRES_DB")
+	if pgUser == "" || pgPassword == "" || pgHost == "" || pgPort == "" || pgDB == "" {
+		log.Info("Postgres is not configured, message exchanges will not be logged")
+		return nil
+	}
+
+	connStr := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=disable",
+		pgUser, pgPassword, pgHost, pgPort, pgDB)
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		log.Error(err, "Failed to open Postgres connection, message exchanges will not be logged")
+		return nil
+	}
+	return &messageLog{db: db}
+}
+
+// record inserts an entry, ignoring entries that have already been logged
+func (l *messageLog) record(ctx context.Context, entry *messageLogEntry) error {
+	payload := entry.Payload
+	if len(payload) == 0 {
+		payload = json.RawMessage("null")
+	} else if !json.Valid(payload) {
+		// Keep non-JSON payloads as a JSON string
+		payload, _ = json.Marshal(string(payload))
+	}
+
+	_, err := l.db.ExecContext(ctx,
+		`INSERT INTO public.agent_message_log (id, agent_id, direction, sender, target, payload, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (id) DO NOTHING`,
+		entry.ID, entry.AgentID, entry.Direction, entry.Sender, entry.Target, []byte(payload), entry.Timestamp)
+	return err
+}
+
+// history returns a page of an agent's message log
+func (l *messageLog) history(ctx context.Context, q historyQuery) ([]messageLogEntry, error) {
+	conditions := []string{"agent_id = $1"}
+	args := []interface{}{q.AgentID}
+	if !q.Since.IsZero() {
+		args = append(args, q.Since)
+		conditions = append(conditions, fmt.Sprintf("timestamp >= $%d", len(args)))
+	}
+	if !q.Until.IsZero() {
+		args = append(args, q.Until)
+		conditions = append(conditions, fmt.Sprintf("timestamp < $%d", len(args)))
+	}
+	if q.Direction != "" {
+		args = append(args, q.Direction)
+		conditions = append(conditions, fmt.Sprintf("direction = $%d", len(args)))
+	}
+	args = append(args, q.Limit, q.Offset)
+
+	query := fmt.Sprintf(`SELECT id, agent_id, direction, COALESCE(sender, ''), COALESCE(target, ''), payload, timestamp
+		FROM public.agent_message_log
+		WHERE %s
+		ORDER BY timestamp DESC, id
+		LIMIT $%d OFFSET $%d`, strings.Join(conditions, " AND "), len(args)-1, len(args))
+
+	rows, err := l.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]messageLogEntry, 0, q.Limit)
+	for rows.Next() {
+		var entry messageLogEntry
+		var payload []byte
+		if err := rows.Scan(&entry.ID, &entry.AgentID, &entry.Direction, &entry.Sender, &entry.Target, &payload, &entry.Timestamp); err != nil {
+			return nil, err
+		}
+		if payload != nil {
+			entry.Payload = json.RawMessage(payload)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// messageLogAgentID returns the agent_id an agent's messages are logged
+// under. Agent names are only unique within an agent type.
+func messageLogAgentID(agentType, agentName string) string {
+	return agentType + "/" + agentName
+}
+
+// logRequest records a message sent to an agent. Failures are logged but do
+// not fail the request.
+func (h *MessageHandler) logRequest(ctx context.Context, rec *messageRecord, payload json.RawMessage) {
+	if h.messageLog == nil {
+		return
+	}
+	err := h.messageLog.record(ctx, &messageLogEntry{
+		ID:        rec.ID,
+		AgentID:   messageLogAgentID(rec.AgentType, rec.Agent),
+		Direction: DirectionInbound,
+		Sender:    rec.Sender,
+		Target:    rec.Agent,
+		Payload:   payload,
+		Timestamp: rec.CreatedAt,
+	})
+	if err != nil {
+		log.Error(err, "Failed to log message", "agent", rec.Agent, "id", rec.ID)
+	}
+}
+
+// logReply records an agent's reply to a message. Failures are logged but do
+// not fail the request.
+func (h *MessageHandler) logReply(ctx context.Context, rec *messageRecord) {
+	if h.messageLog == nil || rec.Reply == nil {
+		return
+	}
+	timestamp := time.Now()
+	if rec.RepliedAt != nil {
+		timestamp = *rec.RepliedAt
+	}
+	sender := rec.Reply.Sender
+	if sender == "" {
+		sender = rec.Agent
+	}
+	err := h.messageLog.record(ctx, &messageLogEntry{
+		ID:        uuid.NewSHA1(replyLogNamespace, []byte(rec.ID)).String(),
+		AgentID:   messageLogAgentID(rec.AgentType, rec.Agent),
+		Direction: DirectionOutbound,
+		Sender:    sender,
+		Target:    rec.Sender,
+		Payload:   rec.Reply.Payload,
+		Timestamp: timestamp,
+	})
+	if err != nil {
+		log.Error(err, "Failed to log reply", "agent", rec.Agent, "id", rec.ID)
+	}
+}
+
+const (
+	// defaultHistoryLimit is the default page size of the history endpoint
+	defaultHistoryLimit = 50
+	// maxHistoryLimit is the largest page size of the history endpoint
+	maxHistoryLimit = 500
+)
+
+// handleGetHistory returns a page of an agent's logged message exchanges,
+// newest first. It accepts the query parameters since and until (RFC 3339
+// timestamps), direction (inbound or outbound), limit and offset.
+func (h *MessageHandler) handleGetHistory(w http.ResponseWriter, r *http.Request, agent *agentsv1alpha1.Agent) {
+	ctx := r.Context()
+
+	if h.messageLog == nil {
+		http.Error(w, "Message history not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	q := historyQuery{
+		AgentID:   messageLogAgentID(agent.Spec.Type, agent.Name),
+		Direction: query.Get("direction"),
+		Limit:     defaultHistoryLimit,
+	}
+	var err error
+	if s := query.Get("since"); s != "" {
+		if q.Since, err = time.Parse(time.RFC3339, s); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid since: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if s := query.Get("until"); s != "" {
+		if q.Until, err = time.Parse(time.RFC3339, s); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid until: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if q.Direction != "" && q.Direction != DirectionInbound && q.Direction != DirectionOutbound {
+		http.Error(w, fmt.Sprintf("Invalid direction %q", q.Direction), http.StatusBadRequest)
+		return
+	}
+	if s := query.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if q.Limit > maxHistoryLimit {
+			q.Limit = maxHistoryLimit
+		}
+	}
+	if s := query.Get("offset"); s != "" {
+		if q.Offset, err = strconv.Atoi(s); err != nil || q.Offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Fetch one extra entry to find out whether there is a next page
+	page := q
+	page.Limit++
+	entries, err := h.messageLog.history(ctx, page)
+	if err != nil {
+		log.Error(err, "Failed to read message history", "agent", agent.Name)
+		http.Error(w, fmt.Sprintf("Error reading message history: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{}
+	if len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+		response["nextOffset"] = q.Offset + q.Limit
+	}
+	response["messages"] = entries
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+	"github.com/Algoluna/agent-operator/pkg/wire"
+)
+
+// MessageStatus is the delivery status of a message sent through the API
+type MessageStatus string
+
+const (
+	// StatusQueued means the message is in the agent's inbox
+	StatusQueued MessageStatus = "queued"
+	// StatusDelivered means the agent has picked the message up
+	StatusDelivered MessageStatus = "delivered"
+	// StatusReplied means the agent has answered the message
+	StatusReplied MessageStatus = "replied"
+	// StatusTimedOut means no reply arrived before the message's deadline
+	StatusTimedOut MessageStatus = "timed_out"
+)
+
+// messageRecordRetention is how long a message's status and reply remain
+// available after its deadline
+const messageRecordRetention = time.Hour
+
+// messageRecord tracks a message sent through the API until it is answered
+type messageRecord struct {
+	ID        string         `json:"id"`
+	Agent     string         `json:"agent"`
+	AgentType string         `json:"agentType"`
+	Sender    string         `json:"sender,omitempty"`
+	SessionID string         `json:"sessionId,omitempty"`
+	Status    MessageStatus  `json:"status"`
+	ReplyTo   string         `json:"-"`
+	InboxID   string         `json:"inboxId,omitempty"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Deadline  time.Time      `json:"deadline"`
+	RepliedAt *time.Time     `json:"repliedAt,omitempty"`
+	ReplyID   string         `json:"replyId,omitempty"`
+	Reply     *wire.Envelope `json:"reply,omitempty"`
+}
+
+// recordStorage is the stored form of a messageRecord, which unlike the API
+// representation keeps the reply stream
+type recordStorage struct {
+	messageRecord
+	ReplyTo string `json:"replyTo"`
+}
+
+func (rec *messageRecord) key() string {
+	return wire.MessageStatusKey(rec.AgentType, rec.Agent, rec.ID)
+}
+
+// final reports whether the record can no longer change
+func (rec *messageRecord) final() bool {
+	return rec.Status == StatusReplied || rec.Status == StatusTimedOut
+}
+
+// createRecord stores a new record that expires after ttl
+func (h *MessageHandler) createRecord(ctx context.Context, rec *messageRecord, ttl time.Duration) error {
+	data, err := json.Marshal(recordStorage{messageRecord: *rec, ReplyTo: rec.ReplyTo})
+	if err != nil {
+		return err
+	}
+	return h.redis.Set(ctx, rec.key(), data, ttl).Err()
+}
+
+// maxSaveAttempts is how often saveRecord retries when the record changes
+// while it is being saved
+const maxSaveAttempts = 3
+
+// saveRecord overwrites a stored record, keeping its expiry. A stored record
+// that is already final is never overwritten, as another reader of the reply
+// stream got there first; rec is updated to the stored record instead.
+func (h *MessageHandler) saveRecord(ctx context.Context, rec *messageRecord) error {
+	data, err := json.Marshal(recordStorage{messageRecord: *rec, ReplyTo: rec.ReplyTo})
+	if err != nil {
+		return err
+	}
+	key := rec.key()
+	for attempt := 0; attempt < maxSaveAttempts; attempt++ {
+		err = h.redis.Watch(ctx, func(tx *redis.Tx) error {
+			stored, err := decodeRecord(tx.Get(ctx, key).Bytes())
+			if err != nil || stored == nil {
+				// An expired record is not brought back without a TTL
+				return err
+			}
+			if stored.final() {
+				*rec = *stored
+				return nil
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.SetArgs(ctx, key, data, redis.SetArgs{KeepTTL: true})
+				return nil
+			})
+			return err
+		}, key)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return err
+}
+
+// reloadRecord updates rec from the stored record if that has become final
+// in the meantime
+func (h *MessageHandler) reloadRecord(ctx context.Context, rec *messageRecord) error {
+	stored, err := decodeRecord(h.redis.Get(ctx, rec.key()).Bytes())
+	if err != nil {
+		return err
+	}
+	if stored != nil && stored.final() {
+		*rec = *stored
+	}
+	return nil
+}
+
+// loadRecord fetches a stored record, returning nil if it does not exist
+func (h *MessageHandler) loadRecord(ctx context.Context, agent *agentsv1alpha1.Agent, id string) (*messageRecord, error) {
+	return decodeRecord(h.redis.Get(ctx, wire.MessageStatusKey(agent.Spec.Type, agent.Name, id)).Bytes())
+}
+
+// decodeRecord decodes the result of reading a stored record, returning nil
+// if it does not exist
+func decodeRecord(data []byte, err error) (*messageRecord, error) {
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored recordStorage
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode message record: %w", err)
+	}
+	rec := stored.messageRecord
+	rec.ReplyTo = stored.ReplyTo
+	return &rec, nil
+}
+
+// completeRecord stores a replied record, logs the reply and drops the reply
+// stream, which is no longer needed once the reply has been captured. The
+// reply stream of a WebSocket session is shared by all of its messages and
+// left to expire instead.
+func (h *MessageHandler) completeRecord(ctx context.Context, rec *messageRecord) error {
+	if err := h.saveRecord(ctx, rec); err != nil {
+		return err
+	}
+	h.logReply(ctx, rec)
+	if rec.SessionID != "" {
+		return nil
+	}
+	if err := h.redis.Del(ctx, rec.ReplyTo).Err(); err != nil {
+		log.Error(err, "Failed to delete reply stream", "stream", rec.ReplyTo)
+	}
+	return nil
+}
+
+// applyReplyEntry updates a record from an entry of its reply stream and
+// reports whether the entry belonged to the record's message
+func (h *MessageHandler) applyReplyEntry(rec *messageRecord, msg redis.XMessage) bool {
+	env, err := wire.Decode(msg.Values)
+	if err != nil {
+		log.Error(err, "Skipping undecodable reply", "stream", rec.ReplyTo, "id", msg.ID)
+		return false
+	}
+	if env.CorrelationID != rec.ID {
+		return false
+	}
+	switch env.Type {
+	case wire.TypeAck:
+		if rec.Status == StatusQueued {
+			rec.Status = StatusDelivered
+		}
+	case wire.TypeReply:
+		now := time.Now()
+		rec.Status = StatusReplied
+		rec.RepliedAt = &now
+		rec.ReplyID = msg.ID
+		rec.Reply = env
+	default:
+		return false
+	}
+	return true
+}
+
+// refreshRecord brings a record up to date with its reply stream and
+// deadline, storing it if anything changed
+func (h *MessageHandler) refreshRecord(ctx context.Context, rec *messageRecord) error {
+	if rec.final() {
+		return nil
+	}
+	before := rec.Status
+
+	entries, err := h.redis.XRange(ctx, rec.ReplyTo, "-", "+").Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	for _, msg := range entries {
+		h.applyReplyEntry(rec, msg)
+		if rec.Status == StatusReplied {
+			return h.completeRecord(ctx, rec)
+		}
+	}
+
+	if time.Now().After(rec.Deadline) {
+		rec.Status = StatusTimedOut
+	}
+	if rec.Status == before {
+		return nil
+	}
+	return h.saveRecord(ctx, rec)
+}
+
+// handleGetMessageStatus reports the status of a message and, once the agent
+// has answered, its reply
+func (h *MessageHandler) handleGetMessageStatus(w http.ResponseWriter, r *http.Request, agent *agentsv1alpha1.Agent, messageID string) {
+	ctx := r.Context()
+
+	rec, err := h.loadRecord(ctx, agent, messageID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error reading message status: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if rec == nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.refreshRecord(ctx, rec); err != nil {
+		http.Error(w, fmt.Sprintf("Error updating message status: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if rec.Reply != nil && !h.checkOutput(w, r, agent, rec) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rec)
+}
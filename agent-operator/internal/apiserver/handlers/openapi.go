@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Algoluna/agent-operator/pkg/apiclient"
+)
+
+// ServeOpenAPI serves the OpenAPI document describing the API
+func ServeOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(apiclient.OpenAPISpec)
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+)
+
+// bucketSweepInterval is how often buckets that have refilled completely,
+// and so carry no state worth keeping, are dropped
+const bucketSweepInterval = time.Minute
+
+// RateLimits configures the token buckets that limit sending messages. A
+// limit with a zero MessagesPerMinute is not enforced.
+type RateLimits struct {
+	// PerCaller limits each caller, identified by its authenticated user,
+	// X-User-ID header or, failing both, its address
+	PerCaller agentsv1alpha1.RateLimit
+
+	// PerAgent limits the messages sent to each agent, unless the agent sets
+	// spec.messaging.rateLimit
+	PerAgent agentsv1alpha1.RateLimit
+}
+
+// RateLimiter enforces RateLimits. It is safe for concurrent use and meant
+// to be shared by every server that accepts messages.
+type RateLimiter struct {
+	limits RateLimits
+
+	mu        sync.Mutex
+	callers   map[string]*rate.Limiter
+	agents    map[string]*rate.Limiter
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates a rate limiter with empty buckets
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	return &RateLimiter{
+		limits:    limits,
+		callers:   map[string]*rate.Limiter{},
+		agents:    map[string]*rate.Limiter{},
+		lastSweep: time.Now(),
+	}
+}
+
+// reserve takes a token from the caller's and the agent's bucket. If either
+// bucket is empty, it takes none and returns how long the caller should wait
+// before trying again.
+func (l *RateLimiter) reserve(caller string, agent *agentsv1alpha1.Agent) (time.Duration, bool) {
+	if l == nil {
+		return 0, true
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) > bucketSweepInterval {
+		sweep(l.callers, now)
+		sweep(l.agents, now)
+		l.lastSweep = now
+	}
+
+	agentLimit := l.limits.PerAgent
+	if agent.Spec.Messaging != nil && agent.Spec.Messaging.RateLimit != nil {
+		agentLimit = *agent.Spec.Messaging.RateLimit
+	}
+
+	var reservations []*rate.Reservation
+	for _, b := range []struct {
+		buckets map[string]*rate.Limiter
+		key     string
+		limit   agentsv1alpha1.RateLimit
+	}{
+		{l.callers, caller, l.limits.PerCaller},
+		{l.agents, agent.Namespace + "/" + agent.Name, agentLimit},
+	} {
+		limiter := bucket(b.buckets, b.key, b.limit)
+		if limiter == nil {
+			continue
+		}
+		r := limiter.ReserveN(now, 1)
+		if delay := r.DelayFrom(now); !r.OK() || delay > 0 {
+			r.CancelAt(now)
+			for _, taken := range reservations {
+				taken.CancelAt(now)
+			}
+			if !r.OK() {
+				delay = time.Minute
+			}
+			return delay, false
+		}
+		reservations = append(reservations, r)
+	}
+	return 0, true
+}
+
+// bucket returns the token bucket of key, creating it or adjusting it to a
+// changed limit. It returns nil if limit is not enforced. The caller must
+// hold the rate limiter's lock.
+func bucket(buckets map[string]*rate.Limiter, key string, limit agentsv1alpha1.RateLimit) *rate.Limiter {
+	if limit.MessagesPerMinute <= 0 {
+		delete(buckets, key)
+		return nil
+	}
+	r := rate.Limit(float64(limit.MessagesPerMinute) / 60)
+	burst := int(limit.Burst)
+	if burst <= 0 {
+		burst = int(limit.MessagesPerMinute)
+	}
+
+	limiter, ok := buckets[key]
+	if !ok {
+		limiter = rate.NewLimiter(r, burst)
+		buckets[key] = limiter
+		return limiter
+	}
+	if limiter.Limit() != r {
+		limiter.SetLimit(r)
+	}
+	if limiter.Burst() != burst {
+		limiter.SetBurst(burst)
+	}
+	return limiter
+}
+
+// sweep drops full buckets, which behave exactly like new ones
+func sweep(buckets map[string]*rate.Limiter, now time.Time) {
+	for key, limiter := range buckets {
+		if limiter.TokensAt(now) >= float64(limiter.Burst()) {
+			delete(buckets, key)
+		}
+	}
+}
+
+// callerKey identifies the caller of an HTTP request for rate limiting
+func callerKey(r *http.Request) string {
+	if sender := senderOf(r); sender != "" {
+		return "user:" + sender
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+// retryAfterSeconds rounds a delay up to the whole seconds of a Retry-After
+// header
+func retryAfterSeconds(delay time.Duration) int {
+	return int(math.Ceil(delay.Seconds()))
+}
+
+// allowMessage applies the rate limits to a message sent over HTTP, replying
+// with 429 and a Retry-After header if it is over a limit
+func (h *MessageHandler) allowMessage(w http.ResponseWriter, r *http.Request, agent *agentsv1alpha1.Agent) bool {
+	delay, ok := h.limiter.reserve(callerKey(r), agent)
+	if ok {
+		return true
+	}
+	log.Info("Rate limited message", "agent", agent.Name, "caller", callerKey(r), "retryAfter", delay)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(delay)))
+	http.Error(w, fmt.Sprintf("Rate limit exceeded, retry in %ds", retryAfterSeconds(delay)), http.StatusTooManyRequests)
+	return false
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+)
+
+func testAgent(name string, limit *agentsv1alpha1.RateLimit) *agentsv1alpha1.Agent {
+	agent := &agentsv1alpha1.Agent{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "agent-hello"}}
+	if limit != nil {
+		agent.Spec.Messaging = &agentsv1alpha1.MessagingSpec{RateLimit: limit}
+	}
+	return agent
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	// Buckets refill by one token a minute, so they do not refill noticeably
+	// while the test runs
+	perCaller := agentsv1alpha1.RateLimit{MessagesPerMinute: 1, Burst: 2}
+	perAgent := agentsv1alpha1.RateLimit{MessagesPerMinute: 1, Burst: 1}
+
+	type send struct {
+		caller string
+		agent  *agentsv1alpha1.Agent
+		wantOK bool
+	}
+	a, b, c := testAgent("a", nil), testAgent("b", nil), testAgent("c", nil)
+	tests := []struct {
+		name   string
+		limits RateLimits
+		sends  []send
+	}{
+		{
+			name:   "agent bucket rejection refunds the caller bucket",
+			limits: RateLimits{PerCaller: perCaller, PerAgent: perAgent},
+			sends: []send{
+				{"alice", a, true},
+				// Rejected by the agent bucket; alice keeps her second token
+				{"alice", a, false},
+				{"alice", b, true},
+				// alice's bucket is empty now
+				{"alice", c, false},
+				// and rejecting her did not take c's token
+				{"bob", c, true},
+			},
+		},
+		{
+			name:   "unenforced limits",
+			limits: RateLimits{},
+			sends:  []send{{"alice", a, true}, {"alice", a, true}, {"alice", a, true}},
+		},
+		{
+			name:   "agent overrides the default agent limit",
+			limits: RateLimits{PerAgent: perAgent},
+			sends: []send{
+				{"alice", testAgent("a", &agentsv1alpha1.RateLimit{MessagesPerMinute: 1, Burst: 3}), true},
+				{"alice", testAgent("a", &agentsv1alpha1.RateLimit{MessagesPerMinute: 1, Burst: 3}), true},
+				{"alice", testAgent("a", &agentsv1alpha1.RateLimit{MessagesPerMinute: 1, Burst: 3}), true},
+				{"alice", testAgent("a", &agentsv1alpha1.RateLimit{MessagesPerMinute: 1, Burst: 3}), false},
+				{"alice", b, true},
+				{"alice", b, false},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter(tt.limits)
+			for i, s := range tt.sends {
+				delay, ok := l.reserve(s.caller, s.agent)
+				if ok != s.wantOK {
+					t.Fatalf("send %d from %s to %s: ok = %v, want %v", i, s.caller, s.agent.Name, ok, s.wantOK)
+				}
+				if ok && delay != 0 {
+					t.Fatalf("send %d: allowed with delay %v", i, delay)
+				}
+				if !ok && (delay <= 0 || delay > time.Minute) {
+					t.Fatalf("send %d: rejected with delay %v, want one within a minute", i, delay)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterNil(t *testing.T) {
+	var l *RateLimiter
+	if _, ok := l.reserve("alice", testAgent("a", nil)); !ok {
+		t.Fatal("a nil rate limiter rejected a message")
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	for delay, want := range map[time.Duration]int{
+		0:                       0,
+		time.Millisecond:        1,
+		time.Second:             1,
+		1500 * time.Millisecond: 2,
+	} {
+		if got := retryAfterSeconds(delay); got != want {
+			t.Errorf("retryAfterSeconds(%v) = %d, want %d", delay, got, want)
+		}
+	}
+}
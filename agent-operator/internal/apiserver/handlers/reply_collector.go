@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/Algoluna/agent-operator/pkg/wire"
+)
+
+const (
+	// replyCollectInterval is how often pending messages are checked for
+	// replies nobody has asked for yet
+	replyCollectInterval = 15 * time.Second
+
+	// replyCollectBatch is how many pending messages are read at a time
+	replyCollectBatch = 100
+)
+
+// ReplyCollector captures the replies to messages nobody is waiting for,
+// such as asynchronous messages whose status is never polled, so they are
+// logged and their records completed. It implements manager.Runnable.
+type ReplyCollector struct {
+	messages *MessageHandler
+}
+
+// NewReplyCollector creates a new reply collector for the messages sent
+// through the given message handler
+func NewReplyCollector(messages *MessageHandler) *ReplyCollector {
+	return &ReplyCollector{messages: messages}
+}
+
+// Start implements manager.Runnable
+func (c *ReplyCollector) Start(ctx context.Context) error {
+	if c.messages.redis == nil {
+		log.Info("Valkey connection not available, replies are only captured when polled")
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(replyCollectInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := c.collect(ctx); err != nil && ctx.Err() == nil {
+				log.Error(err, "Failed to collect replies")
+			}
+		}
+	}
+}
+
+// collect brings every pending message up to date with its reply stream and
+// forgets the messages that are final or whose record has expired
+func (c *ReplyCollector) collect(ctx context.Context) error {
+	h := c.messages
+	var offset int64
+	for {
+		keys, err := h.redis.ZRange(ctx, wire.PendingMessagesKey, offset, offset+replyCollectBatch-1).Result()
+		if err != nil {
+			return err
+		}
+		var done []interface{}
+		for _, key := range keys {
+			rec, err := decodeRecord(h.redis.Get(ctx, key).Bytes())
+			if err != nil {
+				log.Error(err, "Failed to load pending message", "key", key)
+				continue
+			}
+			if rec != nil {
+				if err := h.refreshRecord(ctx, rec); err != nil {
+					log.Error(err, "Failed to update message status", "id", rec.ID)
+					continue
+				}
+			}
+			if rec == nil || rec.final() {
+				done = append(done, key)
+			}
+		}
+		if len(done) > 0 {
+			if err := h.redis.ZRem(ctx, wire.PendingMessagesKey, done...).Err(); err != nil {
+				return err
+			}
+		}
+		if len(keys) < replyCollectBatch {
+			return nil
+		}
+		offset += int64(len(keys) - len(done))
+	}
+}
+
+// trackPending adds a message to the messages the reply collector watches
+func (h *MessageHandler) trackPending(ctx context.Context, rec *messageRecord) {
+	err := h.redis.ZAdd(ctx, wire.PendingMessagesKey, redis.Z{
+		Score:  float64(rec.Deadline.Unix()),
+		Member: rec.key(),
+	}).Err()
+	if err != nil {
+		log.Error(err, "Failed to track pending message", "id", rec.ID)
+	}
+}
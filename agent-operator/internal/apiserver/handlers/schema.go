@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get
+
+// defaultSchemaKey is the ConfigMap key a schema is read from if its
+// reference names none
+const defaultSchemaKey = "schema.json"
+
+// schemaRecheckInterval is how long a compiled schema is used before its
+// ConfigMap is read again to see whether it changed
+const schemaRecheckInterval = 30 * time.Second
+
+var (
+	// schemaMessages renders validation errors
+	schemaMessages = message.NewPrinter(language.English)
+
+	// jsonPointerEscaper escapes a reference token of a JSON pointer
+	jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+)
+
+// fieldError is a single violation of a message schema
+type fieldError struct {
+	// Field is the JSON pointer of the offending value within the payload
+	Field   string
+	Message string
+}
+
+func (e fieldError) String() string {
+	return e.Field + ": " + e.Message
+}
+
+// schemaViolation is returned when a payload does not match its schema
+type schemaViolation struct {
+	ref    string
+	errors []fieldError
+}
+
+func (e *schemaViolation) Error() string {
+	msgs := make([]string, 0, len(e.errors))
+	for _, fe := range e.errors {
+		msgs = append(msgs, fe.String())
+	}
+	return fmt.Sprintf("payload does not match schema %s: %s", e.ref, strings.Join(msgs, "; "))
+}
+
+// compiledSchema is a schema compiled from a version of a ConfigMap
+type compiledSchema struct {
+	resourceVersion string
+	schema          *jsonschema.Schema
+	// checked is when the ConfigMap was last seen at resourceVersion
+	checked time.Time
+}
+
+// noExternalRefs refuses to load the documents schemas refer to, so that a
+// schema can neither read files on the operator's pod nor fetch URLs. The
+// JSON Schema meta-schemas are built in and still resolve.
+type noExternalRefs struct{}
+
+func (noExternalRefs) Load(url string) (any, error) {
+	return nil, fmt.Errorf("external reference %s is not allowed", url)
+}
+
+// schemaCache resolves the input and output schema references of agents
+// to compiled JSON Schemas. A reference names a ConfigMap in the agent's
+// namespace, optionally followed by a slash and the key holding the schema,
+// e.g. "hello-input" or "hello-schemas/input.json". ConfigMaps are read
+// from the API server rather than watched, which would cache every
+// ConfigMap in the cluster. Compiled schemas are cached by the ConfigMap's
+// resourceVersion, which is read again at most every schemaRecheckInterval,
+// so a changed schema takes effect within that interval. Schemas may only
+// refer to definitions within themselves.
+type schemaCache struct {
+	client client.Reader
+
+	mu       sync.Mutex
+	compiled map[string]compiledSchema
+}
+
+func newSchemaCache(client client.Reader) *schemaCache {
+	return &schemaCache{
+		client:   client,
+		compiled: map[string]compiledSchema{},
+	}
+}
+
+// load returns the schema a reference of an agent resolves to, compiling it
+// again only when its ConfigMap has changed
+func (c *schemaCache) load(ctx context.Context, agent *agentsv1alpha1.Agent, ref string) (*jsonschema.Schema, error) {
+	name, key, _ := strings.Cut(ref, "/")
+	if key == "" {
+		key = defaultSchemaKey
+	}
+
+	id := agent.Namespace + "/" + name + "/" + key
+	c.mu.Lock()
+	cached, ok := c.compiled[id]
+	c.mu.Unlock()
+	if ok && time.Since(cached.checked) < schemaRecheckInterval {
+		return cached.schema, nil
+	}
+
+	var cm corev1.ConfigMap
+	if err := c.client.Get(ctx, types.NamespacedName{Namespace: agent.Namespace, Name: name}, &cm); err != nil {
+		return nil, fmt.Errorf("failed to get schema ConfigMap %s: %w", name, err)
+	}
+	if ok && cached.resourceVersion == cm.ResourceVersion {
+		cached.checked = time.Now()
+		c.mu.Lock()
+		c.compiled[id] = cached
+		c.mu.Unlock()
+		return cached.schema, nil
+	}
+
+	data, ok := cm.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("schema ConfigMap %s has no key %q", name, key)
+	}
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("schema %s is not valid JSON: %w", ref, err)
+	}
+	url := "configmap:///" + id
+	compiler := jsonschema.NewCompiler()
+	compiler.UseLoader(noExternalRefs{})
+	if err := compiler.AddResource(url, doc); err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", ref, err)
+	}
+	schema, err := compiler.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", ref, err)
+	}
+
+	c.mu.Lock()
+	c.compiled[id] = compiledSchema{resourceVersion: cm.ResourceVersion, schema: schema, checked: time.Now()}
+	c.mu.Unlock()
+	return schema, nil
+}
+
+// validate checks a payload against the schema a reference of an agent
+// resolves to. It returns a schemaViolation if the payload does not match,
+// or another error if the schema cannot be loaded. Payloads of agents
+// without a reference are not checked.
+func (c *schemaCache) validate(ctx context.Context, agent *agentsv1alpha1.Agent, ref string, payload json.RawMessage) error {
+	if ref == "" {
+		return nil
+	}
+	schema, err := c.load(ctx, agent, ref)
+	if err != nil {
+		return err
+	}
+
+	if len(payload) == 0 {
+		payload = json.RawMessage("null")
+	}
+	value, err := jsonschema.UnmarshalJSON(bytes.NewReader(payload))
+	if err != nil {
+		return &schemaViolation{ref: ref, errors: []fieldError{{Field: "/", Message: err.Error()}}}
+	}
+	err = schema.Validate(value)
+	if err == nil {
+		return nil
+	}
+	verr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+	violation := &schemaViolation{ref: ref}
+	collectFieldErrors(verr, &violation.errors)
+	return violation
+}
+
+// validateInput checks a message payload against the agent's input schema
+func (c *schemaCache) validateInput(ctx context.Context, agent *agentsv1alpha1.Agent, payload json.RawMessage) error {
+	return c.validate(ctx, agent, agent.Spec.InputSchemaRef, payload)
+}
+
+// validateOutput checks a reply payload against the agent's output schema
+func (c *schemaCache) validateOutput(ctx context.Context, agent *agentsv1alpha1.Agent, payload json.RawMessage) error {
+	return c.validate(ctx, agent, agent.Spec.OutputSchemaRef, payload)
+}
+
+// collectFieldErrors flattens a validation error into the violations at its
+// leaves, which name the offending fields
+func collectFieldErrors(err *jsonschema.ValidationError, errs *[]fieldError) {
+	if len(err.Causes) > 0 {
+		for _, cause := range err.Causes {
+			collectFieldErrors(cause, errs)
+		}
+		return
+	}
+	tokens := make([]string, len(err.InstanceLocation))
+	for i, tok := range err.InstanceLocation {
+		tokens[i] = jsonPointerEscaper.Replace(tok)
+	}
+	field := "/" + strings.Join(tokens, "/")
+	*errs = append(*errs, fieldError{Field: field, Message: err.ErrorKind.LocalizedString(schemaMessages)})
+}
+
+// checkInput validates the payload of a message sent over HTTP, replying
+// with 400 and the offending fields if it does not match the agent's input
+// schema
+func (h *MessageHandler) checkInput(w http.ResponseWriter, r *http.Request, agent *agentsv1alpha1.Agent, payload json.RawMessage) bool {
+	err := h.schemas.validateInput(r.Context(), agent, payload)
+	if err == nil {
+		return true
+	}
+	var violation *schemaViolation
+	if errors.As(err, &violation) {
+		http.Error(w, fmt.Sprintf("Invalid payload: %v", err), http.StatusBadRequest)
+		return false
+	}
+	http.Error(w, fmt.Sprintf("Error loading input schema: %v", err), http.StatusInternalServerError)
+	return false
+}
+
+// checkOutput validates the reply of a message returned over HTTP, replying
+// with 502 and the offending fields if it does not match the agent's output
+// schema
+func (h *MessageHandler) checkOutput(w http.ResponseWriter, r *http.Request, agent *agentsv1alpha1.Agent, rec *messageRecord) bool {
+	err := h.schemas.validateOutput(r.Context(), agent, rec.Reply.Payload)
+	if err == nil {
+		return true
+	}
+	var violation *schemaViolation
+	if errors.As(err, &violation) {
+		log.Info("Agent reply does not match its output schema", "agent", agent.Name, "id", rec.ID, "error", err.Error())
+		http.Error(w, fmt.Sprintf("Agent reply does not match its output schema: %v", err), http.StatusBadGateway)
+		return false
+	}
+	http.Error(w, fmt.Sprintf("Error loading output schema: %v", err), http.StatusInternalServerError)
+	return false
+}
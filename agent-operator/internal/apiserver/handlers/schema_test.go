@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// newTestSchemaCache returns a schema cache reading the given schemas from
+// a ConfigMap named schemas, and a count of the ConfigMap reads
+func newTestSchemaCache(t *testing.T, schemas map[string]string) (*schemaCache, client.Client, *int) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "schemas", Namespace: "agent-hello"},
+		Data:       schemas,
+	}
+	gets := 0
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cm).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			gets++
+			return c.Get(ctx, key, obj, opts...)
+		},
+	}).Build()
+	return newSchemaCache(c), c, &gets
+}
+
+func TestSchemaCacheRefs(t *testing.T) {
+	cache, _, _ := newTestSchemaCache(t, map[string]string{
+		"local.json":  `{"$defs": {"name": {"type": "string"}}, "properties": {"name": {"$ref": "#/$defs/name"}}}`,
+		"file.json":   `{"$ref": "file:///etc/passwd"}`,
+		"remote.json": `{"$ref": "https://example.com/schema.json"}`,
+		"meta.json":   `{"$schema": "https://json-schema.org/draft/2020-12/schema", "type": "object"}`,
+	})
+	agent := testAgent("hello", nil)
+
+	for _, ref := range []string{"schemas/local.json", "schemas/meta.json"} {
+		if _, err := cache.load(context.Background(), agent, ref); err != nil {
+			t.Errorf("load(%s): %v", ref, err)
+		}
+	}
+	for _, ref := range []string{"schemas/file.json", "schemas/remote.json"} {
+		if _, err := cache.load(context.Background(), agent, ref); err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Errorf("load(%s) = %v, want an external reference error", ref, err)
+		}
+	}
+
+	err := cache.validate(context.Background(), agent, "schemas/local.json", json.RawMessage(`{"name": 1}`))
+	var violation *schemaViolation
+	if !errors.As(err, &violation) || len(violation.errors) != 1 || violation.errors[0].Field != "/name" {
+		t.Fatalf("validate = %v, want a violation of /name", err)
+	}
+}
+
+func TestSchemaCacheReload(t *testing.T) {
+	cache, c, gets := newTestSchemaCache(t, map[string]string{defaultSchemaKey: `{"type": "object"}`})
+	agent := testAgent("hello", nil)
+	ctx := context.Background()
+
+	first, err := cache.load(ctx, agent, "schemas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := cache.load(ctx, agent, "schemas"); again != first || *gets != 1 {
+		t.Fatalf("schema was read %d times, want it cached after the first", *gets)
+	}
+
+	// Once the recheck interval passes, an unchanged ConfigMap is read but
+	// its schema not compiled again
+	age := func() {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		for id, cached := range cache.compiled {
+			cached.checked = time.Now().Add(-schemaRecheckInterval)
+			cache.compiled[id] = cached
+		}
+	}
+	age()
+	if again, _ := cache.load(ctx, agent, "schemas"); again != first || *gets != 2 {
+		t.Fatalf("unchanged schema was recompiled or not rechecked (%d reads)", *gets)
+	}
+
+	var cm corev1.ConfigMap
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "agent-hello", Name: "schemas"}, &cm); err != nil {
+		t.Fatal(err)
+	}
+	cm.Data[defaultSchemaKey] = `{"type": "array"}`
+	if err := c.Update(ctx, &cm); err != nil {
+		t.Fatal(err)
+	}
+	age()
+	changed, err := cache.load(ctx, agent, "schemas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == first {
+		t.Fatal("changed schema was not recompiled")
+	}
+}
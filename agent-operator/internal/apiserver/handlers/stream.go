@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+	"github.com/Algoluna/agent-operator/pkg/wire"
+)
+
+// streamBlockTimeout bounds each XREAD BLOCK call, so client disconnects are
+// noticed and a keep-alive comment is sent at least this often
+const streamBlockTimeout = 15 * time.Second
+
+// handleStreamMessages tails an agent's outbox stream and pushes every entry
+// to the client as a Server-Sent Event. The stream entry ID is used as the
+// event ID, so clients that reconnect with Last-Event-ID resume where they
+// left off. An optional correlation_id query parameter restricts the events
+// to those belonging to a single request.
+func (h *MessageHandler) handleStreamMessages(w http.ResponseWriter, r *http.Request, agent *agentsv1alpha1.Agent) {
+	ctx := r.Context()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// Resume after the last event the client has seen, otherwise only send
+	// entries written from now on
+	cursor := r.Header.Get("Last-Event-ID")
+	if cursor == "" {
+		cursor = r.URL.Query().Get("lastEventId")
+	}
+	if cursor == "" {
+		cursor = "$"
+	}
+	correlationID := r.URL.Query().Get("correlation_id")
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	log.Info("Streaming agent outbox", "agent", agent.Name, "stream", wire.OutboxKey(agent.Spec.Type, agent.Name), "from", cursor)
+
+	err := h.followOutbox(ctx, agent, cursor, correlationID, func(id string, env *wire.Envelope) error {
+		data, err := json.Marshal(env)
+		if err != nil {
+			return nil
+		}
+		event := env.Type
+		if event == "" {
+			event = "message"
+		}
+		fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, event, data)
+		flusher.Flush()
+		return nil
+	}, func(id string, env *wire.Envelope, violation error) error {
+		data, err := json.Marshal(map[string]string{
+			"correlation_id": env.CorrelationID,
+			"error":          fmt.Sprintf("Agent reply does not match its output schema: %v", violation),
+		})
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintf(w, "id: %s\nevent: error\ndata: %s\n\n", id, data)
+		flusher.Flush()
+		return nil
+	}, func() error {
+		// Nothing new, keep the connection alive through proxies
+		fmt.Fprint(w, ": keep-alive\n\n")
+		flusher.Flush()
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(w, "event: error\ndata: %q\n\n", err.Error())
+		flusher.Flush()
+	}
+}
+
+// followOutbox tails an agent's outbox after cursor ("$" for new entries
+// only) and calls send with every entry, or only those of correlationID if
+// it is set. Replies that do not match the agent's output schema are passed
+// to reject with the schemaViolation instead. idle is called whenever
+// streamBlockTimeout passes without new entries. It returns nil once ctx is
+// done, otherwise the first error from reading the stream, loading the
+// output schema, send, reject or idle.
+func (h *MessageHandler) followOutbox(ctx context.Context, agent *agentsv1alpha1.Agent, cursor, correlationID string,
+	send func(id string, env *wire.Envelope) error, reject func(id string, env *wire.Envelope, violation error) error, idle func() error) error {
+	outboxKey := wire.OutboxKey(agent.Spec.Type, agent.Name)
+
+	for {
+		res, err := h.redis.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{outboxKey, cursor},
+			Count:   100,
+			Block:   streamBlockTimeout,
+		}).Result()
+
+		if ctx.Err() != nil {
+			// Client went away
+			return nil
+		}
+		if err != nil && err != redis.Nil {
+			log.Error(err, "Error reading outbox stream", "stream", outboxKey)
+			return err
+		}
+
+		if len(res) == 0 {
+			if err := idle(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, msg := range res[0].Messages {
+			cursor = msg.ID
+			env, err := wire.Decode(msg.Values)
+			if err != nil {
+				log.Error(err, "Skipping undecodable outbox entry", "stream", outboxKey, "id", msg.ID)
+				continue
+			}
+			if correlationID != "" && env.CorrelationID != correlationID {
+				continue
+			}
+			if env.Type == wire.TypeReply {
+				err := h.schemas.validateOutput(ctx, agent, env.Payload)
+				var violation *schemaViolation
+				if errors.As(err, &violation) {
+					log.Info("Agent reply does not match its output schema", "agent", agent.Name, "id", env.CorrelationID, "error", err.Error())
+					if err := reject(msg.ID, env, err); err != nil {
+						return err
+					}
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("failed to load output schema: %w", err)
+				}
+			}
+			if err := send(msg.ID, env); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,467 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/redis/go-redis/v9"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+	"github.com/Algoluna/agent-operator/pkg/wire"
+)
+
+const (
+	// wsHeartbeatInterval is how often the server pings a WebSocket client.
+	// A client that sends nothing, not even a pong, for two intervals is
+	// disconnected.
+	wsHeartbeatInterval = 30 * time.Second
+
+	// wsWriteTimeout bounds writing a single frame to the client
+	wsWriteTimeout = 10 * time.Second
+
+	// wsPollInterval bounds each read of the session's reply stream, so
+	// messages that are not answered in time are noticed
+	wsPollInterval = 5 * time.Second
+
+	// wsSessionRetention is how long a session can be resumed after the
+	// last heartbeat or outstanding message
+	wsSessionRetention = 5 * time.Minute
+
+	// wsMaxFrameSize limits the size of frames sent by the client
+	wsMaxFrameSize = 1 << 20
+)
+
+// WebSocket frame types sent by the client
+const (
+	wsFrameSend = "send"
+	wsFramePing = "ping"
+)
+
+// WebSocket frame types sent by the server, in addition to the wire message
+// types (ack, chunk, reply) which are forwarded as they arrive
+const (
+	wsFrameSession = "session"
+	wsFrameQueued  = "queued"
+	wsFrameTimeout = "timeout"
+	wsFramePong    = "pong"
+	wsFrameError   = "error"
+)
+
+// wsFrame is a JSON message exchanged over a WebSocket session
+type wsFrame struct {
+	Type string `json:"type"`
+	// Ref is chosen by the client for a send or ping frame and echoed in the
+	// frame that answers it
+	Ref string `json:"ref,omitempty"`
+	// SessionID is set on the session frame sent when the connection opens
+	SessionID string `json:"sessionId,omitempty"`
+	// HeartbeatInterval is the ping interval in seconds, set on the session frame
+	HeartbeatInterval int `json:"heartbeatInterval,omitempty"`
+	// ID is the message ID, which is also the correlation ID of its replies
+	ID string `json:"id,omitempty"`
+	// EventID is the reply stream entry a frame was read from; it can be
+	// passed as lastEventId to resume the session after it
+	EventID string `json:"eventId,omitempty"`
+	// Payload and Timeout (in seconds) are set by the client on send frames
+	Payload json.RawMessage `json:"payload,omitempty"`
+	Timeout int             `json:"timeout,omitempty"`
+	// Message is the envelope of an ack, chunk or reply
+	Message *wire.Envelope `json:"message,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	// RetryAfter is set on errors for send frames that were rate limited or
+	// refused because the agent's inbox is full, in seconds
+	RetryAfter int `json:"retryAfter,omitempty"`
+}
+
+// wsSubprotocol is the only subprotocol the server selects. Browsers that pass
+// their token as a base64url.bearer.authorization.k8s.io. subprotocol must
+// offer it as well, since the token subprotocol is never echoed back and a
+// browser fails the handshake when none of its subprotocols is selected.
+const wsSubprotocol = "agentbox.v1"
+
+var wsUpgrader = websocket.Upgrader{
+	Subprotocols: []string{wsSubprotocol},
+}
+
+// wsSession is an open WebSocket connection to an agent. All messages sent
+// over it share one reply stream, named after the session ID, which a single
+// reader forwards to the client.
+type wsSession struct {
+	h      *MessageHandler
+	agent  *agentsv1alpha1.Agent
+	conn   *websocket.Conn
+	id     string
+	stream string
+	sender string
+	caller string
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]*messageRecord
+}
+
+// handleWebSocket opens a chat session with an agent. Clients send messages
+// as send frames and receive the agent's acks, chunks and replies as they
+// are written. A session can be resumed by reconnecting with its ID in the
+// session query parameter and, optionally, the last eventId received in
+// lastEventId. Clients that offer subprotocols must include agentbox.v1.
+func (h *MessageHandler) handleWebSocket(w http.ResponseWriter, r *http.Request, agent *agentsv1alpha1.Agent) {
+	if protocols := websocket.Subprotocols(r); len(protocols) > 0 && !slices.Contains(protocols, wsSubprotocol) {
+		http.Error(w, fmt.Sprintf("WebSocket clients must offer the %s subprotocol", wsSubprotocol), http.StatusBadRequest)
+		return
+	}
+
+	sessionID := r.URL.Query().Get("session")
+	cursor := r.URL.Query().Get("lastEventId")
+
+	var stream string
+	if sessionID != "" {
+		if _, err := uuid.Parse(sessionID); err != nil {
+			http.Error(w, "Invalid session ID", http.StatusBadRequest)
+			return
+		}
+		stream = wire.ReplyKey(agent.Spec.Type, agent.Name, sessionID)
+		exists, err := h.redis.Exists(r.Context(), stream).Result()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error reading session: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if exists == 0 {
+			http.Error(w, "Session expired", http.StatusGone)
+			return
+		}
+		if cursor == "" {
+			// Replay everything the session stream still holds
+			cursor = "0"
+		}
+	} else {
+		sessionID = uuid.NewString()
+		stream = wire.ReplyKey(agent.Spec.Type, agent.Name, sessionID)
+		marker, err := h.openReplyStream(r.Context(), stream, wsSessionRetention)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to create session: %v", err), http.StatusInternalServerError)
+			return
+		}
+		cursor = marker
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already responded
+		log.Error(err, "Failed to upgrade to WebSocket", "agent", agent.Name)
+		return
+	}
+	defer conn.Close()
+
+	s := &wsSession{
+		h:       h,
+		agent:   agent,
+		conn:    conn,
+		id:      sessionID,
+		stream:  stream,
+		sender:  senderOf(r),
+		caller:  callerKey(r),
+		pending: map[string]*messageRecord{},
+	}
+	log.Info("WebSocket session opened", "agent", agent.Name, "session", sessionID, "sender", s.sender)
+
+	// The request context is not cancelled while the hijacked connection is
+	// open, so the session keeps its own
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.forwardReplies(ctx, cursor)
+	}()
+	go func() {
+		defer wg.Done()
+		s.heartbeat(ctx)
+	}()
+
+	s.write(wsFrame{
+		Type:              wsFrameSession,
+		SessionID:         sessionID,
+		HeartbeatInterval: int(wsHeartbeatInterval / time.Second),
+	})
+	s.readFrames(ctx)
+
+	cancel()
+	wg.Wait()
+	log.Info("WebSocket session closed", "agent", agent.Name, "session", sessionID)
+}
+
+// readFrames handles frames from the client until the connection closes
+func (s *wsSession) readFrames(ctx context.Context) {
+	s.conn.SetReadLimit(wsMaxFrameSize)
+	s.conn.SetReadDeadline(time.Now().Add(2 * wsHeartbeatInterval))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(2 * wsHeartbeatInterval))
+	})
+
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Error(err, "WebSocket session failed", "agent", s.agent.Name, "session", s.id)
+			}
+			return
+		}
+		s.conn.SetReadDeadline(time.Now().Add(2 * wsHeartbeatInterval))
+
+		var frame wsFrame
+		if err := json.Unmarshal(data, &frame); err != nil {
+			s.write(wsFrame{Type: wsFrameError, Error: fmt.Sprintf("Invalid frame: %v", err)})
+			continue
+		}
+		switch frame.Type {
+		case wsFrameSend:
+			s.send(ctx, frame)
+		case wsFramePing:
+			s.write(wsFrame{Type: wsFramePong, Ref: frame.Ref})
+		default:
+			s.write(wsFrame{Type: wsFrameError, Ref: frame.Ref, Error: fmt.Sprintf("Unknown frame type %q", frame.Type)})
+		}
+	}
+}
+
+// send adds a message from a send frame to the agent's inbox
+func (s *wsSession) send(ctx context.Context, frame wsFrame) {
+	if len(frame.Payload) == 0 {
+		s.write(wsFrame{Type: wsFrameError, Ref: frame.Ref, Error: "payload is required"})
+		return
+	}
+	if delay, ok := s.h.limiter.reserve(s.caller, s.agent); !ok {
+		s.write(wsFrame{
+			Type:       wsFrameError,
+			Ref:        frame.Ref,
+			Error:      fmt.Sprintf("Rate limit exceeded, retry in %ds", retryAfterSeconds(delay)),
+			RetryAfter: retryAfterSeconds(delay),
+		})
+		return
+	}
+	if err := s.h.schemas.validateInput(ctx, s.agent, frame.Payload); err != nil {
+		var violation *schemaViolation
+		if errors.As(err, &violation) {
+			s.write(wsFrame{Type: wsFrameError, Ref: frame.Ref, Error: fmt.Sprintf("Invalid payload: %v", err)})
+		} else {
+			s.write(wsFrame{Type: wsFrameError, Ref: frame.Ref, Error: fmt.Sprintf("Error loading input schema: %v", err)})
+		}
+		return
+	}
+	timeout := defaultTimeout
+	if frame.Timeout > 0 {
+		timeout = time.Duration(frame.Timeout) * time.Second
+	}
+
+	// The session stream must live as long as the message's record, like a
+	// reply stream
+	if err := s.h.keepReplyStream(ctx, s.stream, timeout+messageRecordRetention); err != nil {
+		s.write(wsFrame{Type: wsFrameError, Ref: frame.Ref, Error: fmt.Sprintf("Failed to send message: %v", err)})
+		return
+	}
+	rec := &messageRecord{
+		ID:        uuid.NewString(),
+		Sender:    s.sender,
+		SessionID: s.id,
+		ReplyTo:   s.stream,
+	}
+	if err := s.h.addToInbox(ctx, s.agent, rec, frame.Payload, timeout); err != nil {
+		var full *inboxFullError
+		if errors.As(err, &full) {
+			s.write(wsFrame{
+				Type:       wsFrameError,
+				Ref:        frame.Ref,
+				Error:      fmt.Sprintf("Agent is not keeping up: %v", err),
+				RetryAfter: retryAfterSeconds(inboxFullRetryAfter),
+			})
+			return
+		}
+		s.write(wsFrame{Type: wsFrameError, Ref: frame.Ref, Error: fmt.Sprintf("Failed to send message: %v", err)})
+		return
+	}
+
+	s.mu.Lock()
+	s.pending[rec.ID] = rec
+	s.mu.Unlock()
+	s.write(wsFrame{Type: wsFrameQueued, Ref: frame.Ref, ID: rec.ID})
+}
+
+// forwardReplies reads the session stream after cursor and forwards every
+// ack, chunk and reply to the client until ctx is done
+func (s *wsSession) forwardReplies(ctx context.Context, cursor string) {
+	for {
+		res, err := s.h.redis.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{s.stream, cursor},
+			Count:   100,
+			Block:   wsPollInterval,
+		}).Result()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && err != redis.Nil {
+			log.Error(err, "Error reading session stream", "stream", s.stream)
+			s.write(wsFrame{Type: wsFrameError, Error: fmt.Sprintf("Error reading replies: %v", err)})
+			// Ends readFrames, which tears the session down
+			s.conn.Close()
+			return
+		}
+
+		if len(res) > 0 {
+			for _, msg := range res[0].Messages {
+				cursor = msg.ID
+				s.forward(ctx, msg)
+			}
+		}
+		s.expirePending(ctx)
+	}
+}
+
+// forward updates the record of the message an entry of the session stream
+// belongs to and passes the entry on to the client
+func (s *wsSession) forward(ctx context.Context, msg redis.XMessage) {
+	env, err := wire.Decode(msg.Values)
+	if err != nil {
+		log.Error(err, "Skipping undecodable session entry", "stream", s.stream, "id", msg.ID)
+		return
+	}
+	if env.Type == wire.TypeOpen {
+		return
+	}
+
+	if rec := s.record(ctx, env.CorrelationID); rec != nil && !rec.final() {
+		before := rec.Status
+		if s.h.applyReplyEntry(rec, msg) && rec.Status != before {
+			if rec.Status == StatusReplied {
+				err = s.h.completeRecord(ctx, rec)
+				s.mu.Lock()
+				delete(s.pending, rec.ID)
+				s.mu.Unlock()
+			} else {
+				err = s.h.saveRecord(ctx, rec)
+			}
+			if err != nil {
+				log.Error(err, "Failed to update message status", "id", rec.ID)
+			}
+		}
+	}
+
+	if env.Type == wire.TypeReply {
+		if err := s.h.schemas.validateOutput(ctx, s.agent, env.Payload); err != nil {
+			s.write(wsFrame{
+				Type:    wsFrameError,
+				ID:      env.CorrelationID,
+				EventID: msg.ID,
+				Error:   fmt.Sprintf("Agent reply does not match its output schema: %v", err),
+			})
+			return
+		}
+	}
+	s.write(wsFrame{Type: env.Type, ID: env.CorrelationID, EventID: msg.ID, Message: env})
+}
+
+// record returns the record of a message sent over the session, loading it
+// if it was sent before the session was resumed
+func (s *wsSession) record(ctx context.Context, id string) *messageRecord {
+	if id == "" {
+		return nil
+	}
+	s.mu.Lock()
+	rec, ok := s.pending[id]
+	s.mu.Unlock()
+	if ok {
+		return rec
+	}
+
+	rec, err := s.h.loadRecord(ctx, s.agent, id)
+	if err != nil {
+		log.Error(err, "Failed to load message status", "id", id)
+		return nil
+	}
+	if rec == nil || rec.SessionID != s.id {
+		return nil
+	}
+	return rec
+}
+
+// expirePending reports messages of the session whose deadline has passed
+// without a reply
+func (s *wsSession) expirePending(ctx context.Context) {
+	now := time.Now()
+	var expired []*messageRecord
+	s.mu.Lock()
+	for id, rec := range s.pending {
+		if now.After(rec.Deadline) {
+			expired = append(expired, rec)
+			delete(s.pending, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, rec := range expired {
+		rec.Status = StatusTimedOut
+		if err := s.h.saveRecord(ctx, rec); err != nil {
+			log.Error(err, "Failed to record message timeout", "id", rec.ID)
+		}
+		if rec.Status != StatusTimedOut {
+			// The reply was captured elsewhere just before the deadline
+			continue
+		}
+		s.write(wsFrame{Type: wsFrameTimeout, ID: rec.ID, Error: "Timeout waiting for reply"})
+	}
+}
+
+// heartbeat pings the client and keeps the session resumable while the
+// connection is open
+func (s *wsSession) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(wsHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				s.conn.Close()
+				return
+			}
+			if err := s.h.keepReplyStream(ctx, s.stream, wsSessionRetention); err != nil {
+				log.Error(err, "Failed to extend session", "stream", s.stream)
+			}
+		}
+	}
+}
+
+// write sends a frame to the client. Failures close the connection, which
+// ends the session.
+func (s *wsSession) write(frame wsFrame) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if err := s.conn.WriteJSON(frame); err != nil {
+		s.conn.Close()
+	}
+}
+
+// keepReplyStream makes sure a shared reply stream exists and does not
+// expire within ttl
+func (h *MessageHandler) keepReplyStream(ctx context.Context, stream string, ttl time.Duration) error {
+	exists, err := h.redis.Exists(ctx, stream).Result()
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		_, err := h.openReplyStream(ctx, stream, ttl)
+		return err
+	}
+	return h.redis.ExpireGT(ctx, stream, ttl).Err()
+}
@@ -2,9 +2,15 @@ package apiserver
 
 import (
 	"context"
+	"net"
 	"net/http"
+	"time"
 )
 
+// httpShutdownTimeout is how long open requests may take to finish before
+// the HTTP server is closed forcibly
+const httpShutdownTimeout = 10 * time.Second
+
 // Server implements manager.Runnable for the HTTP API server
 type Server struct {
 	httpServer *http.Server
@@ -21,16 +27,34 @@ func NewServer(httpServer *http.Server) *Server {
 func (s *Server) Start(ctx context.Context) error {
 	errCh := make(chan error)
 
+	// Requests are cancelled when the manager stops, so that long-lived
+	// ones such as reply streams end instead of holding up the shutdown
+	s.httpServer.BaseContext = func(net.Listener) context.Context {
+		return ctx
+	}
+
 	go func() {
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.httpServer.TLSConfig != nil {
+			// The certificate comes from the TLS config
+			err = s.httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = s.httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			errCh <- err
 		}
 	}()
 
 	select {
 	case <-ctx.Done():
-		// Graceful shutdown
-		return s.httpServer.Shutdown(context.Background())
+		// Graceful shutdown, cutting off requests that do not end in time
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+		if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
+			return s.httpServer.Close()
+		}
+		return nil
 	case err := <-errCh:
 		return err
 	}
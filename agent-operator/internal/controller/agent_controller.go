@@ -23,11 +23,15 @@ import (
 	"encoding/base64"
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/go-logr/logr"
 
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors" // Alias to avoid confusion with standard errors pkg
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -42,9 +46,8 @@ import (
 	"github.com/lib/pq"
 	_ "github.com/lib/pq" // Import postgres driver
 
-	"github.com/redis/go-redis/v9"
-
 	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+	"github.com/Algoluna/agent-operator/pkg/wire"
 )
 
 const (
@@ -55,7 +58,10 @@ const (
 // AgentReconciler reconciles a Agent object
 type AgentReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	// APIReader reads from the API server directly, for the few lookups
+	// the cache may be too stale for
+	APIReader client.Reader
+	Scheme    *runtime.Scheme
 }
 
 const (
@@ -69,13 +75,16 @@ const (
 // +kubebuilder:rbac:groups=agents.algoluna.com,resources=agents/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=agents.algoluna.com,resources=agents/finalizers,verbs=update
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete # Added Secret permissions
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+// Added Secret permissions
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-func (r *AgentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *AgentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	log := logf.FromContext(ctx)
-	var err error
 
 	// Fetch the Agent instance
 	var agent agentsv1alpha1.Agent
@@ -88,36 +97,45 @@ func (r *AgentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		log.Error(err, "Failed to get Agent")
 		return ctrl.Result{}, err
 	}
+	// The status as it was before this reconciliation, to tell whether it
+	// needs to be written back
+	observed := agent.Status.DeepCopy()
 
 	// --- TTL/LastActivityTime enforcement ---
 	if agent.Spec.TTL > 0 {
 		now := time.Now()
-		// If LastActivityTime is set, check for expiry
-		if agent.Spec.LastActivityTime != nil {
-			elapsed := now.Sub(agent.Spec.LastActivityTime.Time)
-			if elapsed > time.Duration(agent.Spec.TTL)*time.Second {
-				log.Info("Agent TTL expired, deleting agent", "Agent", req.NamespacedName, "TTL", agent.Spec.TTL, "LastActivityTime", agent.Spec.LastActivityTime.Time)
-				// Delete the agent
-				if err := r.Delete(ctx, &agent); err != nil {
-					log.Error(err, "Failed to delete agent after TTL expiry")
-					return ctrl.Result{}, err
-				}
-				return ctrl.Result{}, nil
-			}
-			// Schedule next reconciliation at TTL expiry
-			timeUntilExpiry := time.Duration(agent.Spec.TTL)*time.Second - elapsed
-			return ctrl.Result{RequeueAfter: timeUntilExpiry}, nil
-		} else {
-			// Initialize LastActivityTime if not set
+		// Initialize LastActivityTime if not set
+		if agent.Spec.LastActivityTime == nil {
 			nowMeta := metav1.NewTime(now)
 			agent.Spec.LastActivityTime = &nowMeta
 			if err := r.Update(ctx, &agent); err != nil {
 				log.Error(err, "Failed to initialize LastActivityTime for agent")
 				return ctrl.Result{}, err
 			}
-			// Requeue for TTL check
-			return ctrl.Result{RequeueAfter: time.Duration(agent.Spec.TTL) * time.Second}, nil
 		}
+		ttl := time.Duration(agent.Spec.TTL) * time.Second
+		elapsed := now.Sub(agent.Spec.LastActivityTime.Time)
+		if elapsed > ttl {
+			log.Info("Agent TTL expired, deleting agent", "Agent", req.NamespacedName, "TTL", agent.Spec.TTL, "LastActivityTime", agent.Spec.LastActivityTime.Time)
+			// Delete the agent
+			if err := r.Delete(ctx, &agent); err != nil {
+				log.Error(err, "Failed to delete agent after TTL expiry")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+		setTTLCondition(&agent, agent.Spec.LastActivityTime.Add(ttl))
+
+		// Schedule the next reconciliation at TTL expiry at the latest,
+		// whatever the rest of the reconciliation asks for
+		timeUntilExpiry := ttl - elapsed
+		defer func() {
+			if err == nil && !result.Requeue && (result.RequeueAfter == 0 || result.RequeueAfter > timeUntilExpiry) {
+				result.RequeueAfter = timeUntilExpiry
+			}
+		}()
+	} else {
+		setTTLCondition(&agent, time.Time{})
 	}
 
 	// --- Ensure dedicated namespace for this agent type ---
@@ -159,6 +177,9 @@ func (r *AgentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		createdSecretName, provisionErr := r.provisionPostgresCredentials(ctx, &agent)
 		if provisionErr != nil {
 			log.Error(provisionErr, "Failed to provision Postgres credentials and secret")
+			msg := fmt.Sprintf("Failed to provision credentials: %v", provisionErr)
+			setCondition(&agent, agentsv1alpha1.ConditionCredentialsProvisioned, metav1.ConditionFalse, ReasonProvisioningFailed, msg)
+			setDegraded(&agent, ReasonProvisioningFailed, msg)
 			// Update status and requeue with backoff
 			_, statusErr := r.updateAgentStatus(ctx, &agent, PhaseFailed, fmt.Sprintf("Failed to provision credentials: %v", provisionErr))
 			return ctrl.Result{RequeueAfter: time.Second * 30}, statusErr // Requeue after delay
@@ -180,6 +201,9 @@ func (r *AgentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		createdValkeySecretName, provisionErr := r.provisionValkeyCredentials(ctx, &agent)
 		if provisionErr != nil {
 			log.Error(provisionErr, "Failed to provision Valkey credentials and secret")
+			msg := fmt.Sprintf("Failed to provision valkey credentials: %v", provisionErr)
+			setCondition(&agent, agentsv1alpha1.ConditionCredentialsProvisioned, metav1.ConditionFalse, ReasonProvisioningFailed, msg)
+			setDegraded(&agent, ReasonProvisioningFailed, msg)
 			_, statusErr := r.updateAgentStatus(ctx, &agent, PhaseFailed, fmt.Sprintf("Failed to provision valkey credentials: %v", provisionErr))
 			return ctrl.Result{RequeueAfter: time.Second * 30}, statusErr
 		}
@@ -190,64 +214,171 @@ func (r *AgentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		log.Error(err, "Failed to get Valkey secret", "SecretName", valkeySecretName)
 		return ctrl.Result{}, err
 	}
+	setCondition(&agent, agentsv1alpha1.ConditionCredentialsProvisioned, metav1.ConditionTrue, ReasonProvisioned,
+		fmt.Sprintf("Secrets %s and %s exist", postgresSecretName, valkeySecretName))
 	// --- End of Secret Provisioning Logic ---
 
-	// Check if pod already exists for this agent
-	podName := fmt.Sprintf("agent-%s", agent.Name)
-	var pod corev1.Pod
-	podFound := true
-	err = r.Get(ctx, types.NamespacedName{Name: podName, Namespace: agent.Namespace}, &pod)
+	// Long-running agents may run as a Deployment or StatefulSet instead of
+	// a bare pod, and runOnce agents run as a Job
+	kind := workloadKind(&agent)
+	if kind == workloadKindJob {
+		// Run-once agents started before they ran as Jobs finish as bare pods
+		pods, err := r.listAgentPods(ctx, &agent)
+		if err != nil {
+			log.Error(err, "Failed to list Pods")
+			return ctrl.Result{}, err
+		}
+		if len(pods) > 0 {
+			kind = agentsv1alpha1.WorkloadKindPod
+		}
+	}
+	if err := r.deleteStaleWorkloads(ctx, &agent, kind); err != nil {
+		log.Error(err, "Failed to delete workloads of a previous workload kind")
+		return ctrl.Result{}, err
+	}
+	if kind == workloadKindJob {
+		return r.reconcileJob(ctx, &agent, observed, postgresSecretName, valkeySecretName)
+	}
+	if kind != agentsv1alpha1.WorkloadKindPod {
+		return r.reconcileWorkload(ctx, &agent, kind, observed, postgresSecretName, valkeySecretName)
+	}
 
+	// Find the agent's pod, rolling out spec changes to long-running agents
+	template := withSpecHash(podTemplateForAgent(&agent, postgresSecretName, valkeySecretName, barePodRestartPolicy(&agent)))
+	pods, err := r.listAgentPods(ctx, &agent)
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-			podFound = false
-			// Pod doesn't exist, create it if the Agent is not in a terminal state
-			if agent.Status.Phase != PhaseCompleted && agent.Status.Phase != PhaseFailed {
-				log.Info("Pod not found, creating a new one")
-				// Pass the determined secret names to the pod constructor
-				newPod := r.constructPodForAgent(&agent, postgresSecretName, valkeySecretName)
-				if err := r.Create(ctx, newPod); err != nil {
-					log.Error(err, "Failed to create Pod for Agent", "Pod.Namespace", newPod.Namespace, "Pod.Name", newPod.Name)
-					// Use apierrors here
-					return r.updateAgentStatus(ctx, &agent, PhaseFailed, fmt.Sprintf("Failed to create pod: %v", err))
-				}
-				log.Info("Created Pod for Agent", "Pod.Namespace", newPod.Namespace, "Pod.Name", newPod.Name)
-				return r.updateAgentStatus(ctx, &agent, PhasePending, "Pod created, waiting for it to start")
+		log.Error(err, "Failed to list Pods")
+		return ctrl.Result{}, err
+	}
+	for i := range pods {
+		if err := r.adoptLegacyPod(ctx, &pods[i], template); err != nil {
+			log.Error(err, "Failed to annotate Pod with its spec hash", "Pod.Name", pods[i].Name)
+			return ctrl.Result{}, err
+		}
+	}
+	createPod := func() (*corev1.Pod, error) {
+		newPod := r.constructPodForAgent(&agent, template)
+		return newPod, r.Create(ctx, newPod)
+	}
+
+	var current *corev1.Pod
+	if len(pods) > 0 {
+		current = &pods[0]
+	}
+	if !agent.Spec.RunOnce {
+		serving, rolloutMessage, err := r.rolloutPods(ctx, &agent, pods, template, createPod)
+		if err != nil {
+			log.Error(err, "Failed to roll out Agent spec change")
+			return ctrl.Result{}, err
+		}
+		if rolloutMessage != "" {
+			// The outdated pod keeps serving until the rollout completes
+			setPodStatus(&agent, serving)
+			agent.Status.Phase = PhaseRunning
+			agent.Status.Message = rolloutMessage
+			if !equality.Semantic.DeepEqual(*observed, agent.Status) || agent.Status.ObservedGeneration != agent.Generation {
+				return r.updateAgentStatus(ctx, &agent, PhaseRunning, rolloutMessage)
 			}
-			// If Agent is Completed/Failed and pod is gone, do nothing
 			return ctrl.Result{}, nil
 		}
-		// Other error getting pod
-		log.Error(err, "Failed to get Pod")
-		return ctrl.Result{}, err
+		current = serving
 	}
 
+	podFound := current != nil
+	if !podFound {
+		// Pod doesn't exist, create it if the Agent is not in a terminal state
+		if agent.Status.Phase != PhaseCompleted && agent.Status.Phase != PhaseFailed {
+			log.Info("Pod not found, creating a new one")
+			// Make sure the inbox can be consumed through its consumer group
+			// before the agent starts reading it
+			if err := r.ensureInboxGroup(ctx, &agent); err != nil {
+				log.Error(err, "Failed to provision inbox consumer group")
+				setNotReady(&agent, ReasonPodCreating, fmt.Sprintf("Failed to provision inbox: %v", err))
+				_, statusErr := r.updateAgentStatus(ctx, &agent, PhasePending, fmt.Sprintf("Failed to provision inbox: %v", err))
+				return ctrl.Result{RequeueAfter: time.Second * 30}, statusErr
+			}
+			newPod, err := createPod()
+			if apierrors.IsAlreadyExists(err) {
+				// A pod of the same spec is still terminating, e.g. after a restart
+				log.Info("Pod is still terminating, waiting to recreate it", "Pod.Name", newPod.Name)
+				return ctrl.Result{RequeueAfter: time.Second * 2}, nil
+			}
+			if err != nil {
+				log.Error(err, "Failed to create Pod for Agent", "Pod.Namespace", newPod.Namespace, "Pod.Name", newPod.Name)
+				msg := fmt.Sprintf("Failed to create pod: %v", err)
+				setCondition(&agent, agentsv1alpha1.ConditionPodScheduled, metav1.ConditionFalse, ReasonPodCreateFailed, msg)
+				setNotReady(&agent, ReasonPodCreateFailed, msg)
+				setDegraded(&agent, ReasonPodCreateFailed, msg)
+				// Use apierrors here
+				return r.updateAgentStatus(ctx, &agent, PhaseFailed, msg)
+			}
+			log.Info("Created Pod for Agent", "Pod.Namespace", newPod.Namespace, "Pod.Name", newPod.Name)
+			agent.Status.PodName = newPod.Name
+			agent.Status.StartTime = nil
+			agent.Status.NextRestartTime = nil
+			setCondition(&agent, agentsv1alpha1.ConditionPodScheduled, metav1.ConditionFalse, ReasonPodCreating, "Pod created, waiting for it to be scheduled")
+			setNotReady(&agent, ReasonPodCreating, "Pod created, waiting for it to start")
+			return r.updateAgentStatus(ctx, &agent, PhasePending, "Pod created, waiting for it to start")
+		}
+		// If Agent is Completed/Failed and pod is gone, only record that
+		setNotReady(&agent, ReasonPodGone, fmt.Sprintf("Agent is %s and has no pod", agent.Status.Phase))
+		if !equality.Semantic.DeepEqual(*observed, agent.Status) {
+			return r.updateAgentStatus(ctx, &agent, agent.Status.Phase, agent.Status.Message)
+		}
+		return ctrl.Result{}, nil
+	}
+	pod := *current
+
 	// --- Pod Exists ---
 
 	// Update Agent status based on Pod status
-	currentAgentPhase := agent.Status.Phase
-	newPhase := currentAgentPhase
+	newPhase := agent.Status.Phase
 	newMessage := agent.Status.Message
+	setPodStatus(&agent, &pod)
+	var requeueAfter time.Duration
 
 	switch pod.Status.Phase {
 	case corev1.PodRunning:
 		newPhase = PhaseRunning
 		newMessage = "Agent pod is running"
+		setHealthy(&agent)
+		// Forget earlier restarts once the pod has stayed healthy for long
+		// enough, checking again when it will have
+		if agent.Status.ConsecutiveRestarts > 0 {
+			if since := healthySince(&agent); !since.IsZero() {
+				healthyFor := time.Since(since)
+				if resetAfter := restartBackoffOf(&agent).resetAfter; healthyFor >= resetAfter {
+					agent.Status.ConsecutiveRestarts = 0
+				} else {
+					requeueAfter = resetAfter - healthyFor
+				}
+			}
+		}
 	case corev1.PodSucceeded:
 		// Only transition to Completed if it's a runOnce agent
 		if agent.Spec.RunOnce {
 			newPhase = PhaseCompleted
 			newMessage = "Agent pod completed successfully"
+			if agent.Status.CompletionTime == nil {
+				completed := podCompletionTime(&pod)
+				agent.Status.CompletionTime = &completed
+			}
+			setHealthy(&agent)
 		} else {
 			// For long-running agents, Succeeded means it exited unexpectedly. Treat as failure for restart logic.
 			log.Info("Long-running agent pod Succeeded unexpectedly, treating as failure for potential restart", "Pod.Name", pod.Name)
 			newPhase = PhaseFailed
 			newMessage = "Long-running agent pod completed unexpectedly"
+			agent.Status.LastFailureReason = "ExitedUnexpectedly"
 			// Proceed to failure handling below
 		}
 	case corev1.PodFailed:
 		newPhase = PhaseFailed
-		newMessage = fmt.Sprintf("Agent pod failed: %s", pod.Status.Reason)
+		agent.Status.LastFailureReason = podFailureReason(&pod)
+		newMessage = fmt.Sprintf("Agent pod failed: %s", agent.Status.LastFailureReason)
+		if agent.Spec.RunOnce {
+			setDegraded(&agent, ReasonPodFailed, newMessage)
+		}
 		// Proceed to failure handling below
 	case corev1.PodPending:
 		newPhase = PhasePending
@@ -261,6 +392,28 @@ func (r *AgentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 	if newPhase == PhaseFailed && !agent.Spec.RunOnce && podFound {
 		// Check MaxRestarts (-1 means infinite)
 		if agent.Spec.MaxRestarts == -1 || agent.Status.RestartCount < agent.Spec.MaxRestarts {
+			now := time.Now()
+			// Keep the failed pod around until the backoff delay has passed
+			if agent.Status.NextRestartTime == nil {
+				delay := restartBackoffOf(&agent).delay(agent.Status.ConsecutiveRestarts)
+				next := metav1.NewTime(now.Add(delay))
+				agent.Status.NextRestartTime = &next
+				log.Info("Scheduling restart of failed long-running agent pod", "RestartCount", agent.Status.RestartCount, "MaxRestarts", agent.Spec.MaxRestarts, "delay", delay)
+			}
+			if wait := agent.Status.NextRestartTime.Sub(now); wait > 0 {
+				newMessage = fmt.Sprintf("%s, restarting pod at %s (attempt %d)",
+					newMessage, agent.Status.NextRestartTime.UTC().Format(time.RFC3339), agent.Status.RestartCount+1)
+				setDegraded(&agent, ReasonRestarting, newMessage)
+				agent.Status.Phase = newPhase
+				agent.Status.Message = newMessage
+				if !equality.Semantic.DeepEqual(*observed, agent.Status) || agent.Status.ObservedGeneration != agent.Generation {
+					if _, err := r.updateAgentStatus(ctx, &agent, newPhase, newMessage); err != nil {
+						return ctrl.Result{}, err
+					}
+				}
+				return ctrl.Result{RequeueAfter: wait}, nil
+			}
+
 			log.Info("Attempting to restart failed/completed long-running agent pod", "RestartCount", agent.Status.RestartCount, "MaxRestarts", agent.Spec.MaxRestarts)
 
 			// Delete the failed pod
@@ -269,40 +422,46 @@ func (r *AgentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 				return r.updateAgentStatus(ctx, &agent, PhaseFailed, fmt.Sprintf("Failed to delete pod %s for restart: %v", pod.Name, err))
 			}
 
-			// Increment restart count and update status
+			// Increment restart counts and update status. The reconcile will
+			// trigger again when the pod is deleted, and the 'pod not found'
+			// logic will create a new one.
 			agent.Status.RestartCount++
-			_, updateErr := r.updateAgentStatus(ctx, &agent, PhasePending, fmt.Sprintf("Restarting pod (attempt %d)", agent.Status.RestartCount))
-
-			// Requeue after a backoff period (simple example, could use exponential)
-			// Note: The reconcile will trigger again when the pod is deleted,
-			// and the 'pod not found' logic will create a new one.
-			// Adding a small delay might prevent rapid recreation cycles if creation fails immediately.
-			requeueDelay := time.Second * 5 // Simple delay, consider exponential backoff later
-			log.Info("Requeuing reconciliation after pod deletion for restart", "delay", requeueDelay)
-			return ctrl.Result{RequeueAfter: requeueDelay}, updateErr // Return potential status update error
+			agent.Status.ConsecutiveRestarts++
+			agent.Status.NextRestartTime = nil
+			setDegraded(&agent, ReasonRestarting, fmt.Sprintf("%s, restarting pod (attempt %d)", newMessage, agent.Status.RestartCount))
+			return r.updateAgentStatus(ctx, &agent, PhasePending, fmt.Sprintf("Restarting pod (attempt %d)", agent.Status.RestartCount))
 
 		} else {
 			// Max restarts exceeded
 			log.Info("Max restarts exceeded for agent pod", "MaxRestarts", agent.Spec.MaxRestarts)
-			newMessage = fmt.Sprintf("Agent pod failed and exceeded max restarts (%d): %s", agent.Spec.MaxRestarts, pod.Status.Reason)
+			newMessage = fmt.Sprintf("Agent pod failed and exceeded max restarts (%d): %s", agent.Spec.MaxRestarts, agent.Status.LastFailureReason)
+			setDegraded(&agent, ReasonMaxRestartsExceeded, newMessage)
+			agent.Status.NextRestartTime = nil
 			// Status will be updated below
 		}
 	}
 
-	// Update status if phase or message changed
-	if newPhase != currentAgentPhase || newMessage != agent.Status.Message {
-		return r.updateAgentStatus(ctx, &agent, newPhase, newMessage)
+	// Update status if anything changed, including the generation observed
+	agent.Status.Phase = newPhase
+	agent.Status.Message = newMessage
+	if !equality.Semantic.DeepEqual(*observed, agent.Status) || agent.Status.ObservedGeneration != agent.Generation {
+		if _, err := r.updateAgentStatus(ctx, &agent, newPhase, newMessage); err != nil {
+			return ctrl.Result{}, err
+		}
 	}
 
-	// If nothing changed, no need to requeue
-	return ctrl.Result{}, nil
+	// Nothing left to do, unless the restart backoff is due to be reset
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
-// updateAgentStatus updates the status of the Agent resource.
+// updateAgentStatus updates the status of the Agent resource to the given
+// phase and message, along with the conditions and other status fields set
+// on agent, and records the generation they were observed at.
 func (r *AgentReconciler) updateAgentStatus(ctx context.Context, agent *agentsv1alpha1.Agent, phase string, message string) (ctrl.Result, error) {
 	log := logf.FromContext(ctx)
 	agent.Status.Phase = phase
 	agent.Status.Message = message
+	agent.Status.ObservedGeneration = agent.Generation
 
 	// Use retry loop for status updates to handle potential conflicts
 	// Use apierrors here
@@ -321,9 +480,7 @@ func (r *AgentReconciler) updateAgentStatus(ctx context.Context, agent *agentsv1
 			return getErr
 		}
 		// Apply the changes to the fetched object
-		currentAgent.Status.Phase = phase
-		currentAgent.Status.Message = message
-		currentAgent.Status.RestartCount = agent.Status.RestartCount // Ensure restart count is updated
+		currentAgent.Status = *agent.Status.DeepCopy()
 
 		// Update the status
 		return r.Status().Update(ctx, currentAgent)
@@ -340,17 +497,41 @@ func (r *AgentReconciler) updateAgentStatus(ctx context.Context, agent *agentsv1
 	return ctrl.Result{}, nil
 }
 
-// constructPodForAgent creates a pod object for the given Agent, injecting secret volumes
-func (r *AgentReconciler) constructPodForAgent(agent *agentsv1alpha1.Agent, postgresSecretName, valkeySecretName string) *corev1.Pod {
-	log := logf.Log.WithValues("agent", agent.Name, "namespace", agent.Namespace) // Use logger
-	podName := fmt.Sprintf("agent-%s", agent.Name)
-
+// barePodRestartPolicy returns the restart policy of an Agent run as a bare pod
+func barePodRestartPolicy(agent *agentsv1alpha1.Agent) corev1.RestartPolicy {
 	// Determine RestartPolicy based on RunOnce
 	restartPolicy := corev1.RestartPolicyNever // Default for runOnce=true
 	if !agent.Spec.RunOnce {
 		restartPolicy = corev1.RestartPolicyOnFailure // Or Always? OnFailure seems better with operator restarts.
 	}
+	return restartPolicy
+}
+
+// constructPodForAgent creates a bare pod object for the given Agent from its
+// pod template, named after the template's spec hash so a replacement can
+// run next to an outdated pod
+func (r *AgentReconciler) constructPodForAgent(agent *agentsv1alpha1.Agent, template corev1.PodTemplateSpec) *corev1.Pod {
+	log := logf.Log.WithValues("agent", agent.Name, "namespace", agent.Namespace) // Use logger
 
+	pod := &corev1.Pod{
+		ObjectMeta: *template.ObjectMeta.DeepCopy(),
+		Spec:       *template.Spec.DeepCopy(),
+	}
+	pod.Name = fmt.Sprintf("%s-%s", agentWorkloadName(agent), specHash(&template))
+	pod.Namespace = agent.Namespace
+
+	// Set Agent instance as the owner and controller
+	if err := controllerutil.SetControllerReference(agent, pod, r.Scheme); err != nil {
+		// Log the error, but the reconcile loop should handle it
+		log.Error(err, "Failed to set controller reference on pod") // Use instance logger
+	}
+
+	return pod
+}
+
+// podTemplateForAgent creates the pod template shared by every kind of
+// workload an Agent runs as, injecting secret volumes
+func podTemplateForAgent(agent *agentsv1alpha1.Agent, postgresSecretName, valkeySecretName string, restartPolicy corev1.RestartPolicy) corev1.PodTemplateSpec {
 	// Convert agent.Spec.Env to corev1.EnvVar
 	var envVars []corev1.EnvVar
 	for _, env := range agent.Spec.Env {
@@ -369,6 +550,17 @@ func (r *AgentReconciler) constructPodForAgent(agent *agentsv1alpha1.Agent, post
 		Name:  "AGENT_TYPE", // Agent type
 		Value: agent.Spec.Type,
 	})
+	// Inbox consumption settings for the SDK
+	envVars = append(envVars, corev1.EnvVar{
+		Name:  "AGENT_INBOX_GROUP",
+		Value: wire.InboxGroup,
+	}, corev1.EnvVar{
+		Name:  "AGENT_INBOX_CLAIM_IDLE_MS",
+		Value: strconv.FormatInt(claimIdleSeconds(agent)*1000, 10),
+	}, corev1.EnvVar{
+		Name:  "AGENT_INBOX_MAX_DELIVERIES",
+		Value: strconv.FormatInt(maxDeliveries(agent), 10),
+	})
 
 	// Define Volumes based on provided secret names
 	volumes := []corev1.Volume{}
@@ -388,7 +580,6 @@ func (r *AgentReconciler) constructPodForAgent(agent *agentsv1alpha1.Agent, post
 			MountPath: postgresSecretMountPath,
 			ReadOnly:  true,
 		})
-		log.Info("Adding postgres secret volume mount", "SecretName", postgresSecretName, "MountPath", postgresSecretMountPath)
 	}
 
 	// Add similar logic for valkeySecretName if/when Valkey provisioning is added
@@ -406,19 +597,11 @@ func (r *AgentReconciler) constructPodForAgent(agent *agentsv1alpha1.Agent, post
 			MountPath: valkeySecretMountPath,
 			ReadOnly:  true,
 		})
-		log.Info("Adding valkey secret volume mount", "SecretName", valkeySecretName, "MountPath", valkeySecretMountPath)
 	}
 
-	pod := &corev1.Pod{
+	return corev1.PodTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      podName,
-			Namespace: agent.Namespace,
-			Labels: map[string]string{
-				"app":        "agent",
-				"agent-name": agent.Name,
-				"agent-type": agent.Spec.Type,
-			},
-			// Owner reference is set below using SetControllerReference
+			Labels: agentLabels(agent),
 		},
 		Spec: corev1.PodSpec{
 			RestartPolicy: restartPolicy, // Set based on RunOnce
@@ -434,14 +617,21 @@ func (r *AgentReconciler) constructPodForAgent(agent *agentsv1alpha1.Agent, post
 			Volumes: volumes, // Add the volumes
 		},
 	}
+}
 
-	// Set Agent instance as the owner and controller
-	if err := controllerutil.SetControllerReference(agent, pod, r.Scheme); err != nil {
-		// Log the error, but the reconcile loop should handle it
-		log.Error(err, "Failed to set controller reference on pod") // Use instance logger
+// agentLabels returns the labels of an Agent's pods
+func agentLabels(agent *agentsv1alpha1.Agent) map[string]string {
+	return map[string]string{
+		"app":        "agent",
+		"agent-name": agent.Name,
+		"agent-type": agent.Spec.Type,
 	}
+}
 
-	return pod
+// agentWorkloadName returns the name of the Deployment or StatefulSet an
+// Agent runs as, which its bare pods are named after
+func agentWorkloadName(agent *agentsv1alpha1.Agent) string {
+	return fmt.Sprintf("agent-%s", agent.Name)
 }
 
 /*
@@ -456,29 +646,7 @@ func (r *AgentReconciler) provisionValkeyCredentials(ctx context.Context, agent
 	valkeyUser := fmt.Sprintf("agent_%s", createRoleName(agent.Spec.Type))
 
 	// Valkey connection info
-	valkeyNamespace := os.Getenv("VALKEY_NAMESPACE")
-	if valkeyNamespace == "" {
-		valkeyNamespace = "agentbox-system"
-	}
-	valkeyServiceName := os.Getenv("VALKEY_SERVICE_NAME")
-	if valkeyServiceName == "" {
-		valkeyServiceName = "agentbox-valkey"
-	}
-	valkeyFQDN := fmt.Sprintf("%s.%s.svc.cluster.local", valkeyServiceName, valkeyNamespace)
-	valkeyPort := os.Getenv("VALKEY_PORT")
-	if valkeyPort == "" {
-		valkeyPort = "6379"
-	}
-
-	// Valkey admin credentials (must be set as env vars or via secret)
-	valkeyAdminUser := os.Getenv("VALKEY_ADMIN_USER")
-	if valkeyAdminUser == "" {
-		valkeyAdminUser = "default"
-	}
-	valkeyAdminPassword := os.Getenv("VALKEY_ADMIN_PASSWORD")
-	if valkeyAdminPassword == "" {
-		return "", fmt.Errorf("VALKEY_ADMIN_PASSWORD must be set in the controller environment")
-	}
+	valkeyFQDN, valkeyPort := valkeyAddress()
 
 	// Check if the secret already exists
 	var valkeySecret corev1.Secret
@@ -509,24 +677,18 @@ func (r *AgentReconciler) provisionValkeyCredentials(ctx context.Context, agent
 	}
 
 	// Connect to Valkey as admin
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", valkeyFQDN, valkeyPort),
-		Username: valkeyAdminUser,
-		Password: valkeyAdminPassword,
-	})
-	defer rdb.Close()
-
-	// Test connection
-	ping := rdb.Ping(ctx)
-	if ping.Err() != nil {
-		return "", fmt.Errorf("failed to connect to Valkey as admin: %w", ping.Err())
+	rdb, err := newValkeyAdminClient(ctx)
+	if err != nil {
+		return "", err
 	}
+	defer rdb.Close()
 
-	// Set up ACL for agent-type user: full access to <type>:* and system:*
+	// Set up ACL for agent-type user: full access to agent:<type>:* and system:*
+	// (every stream in the wire format is named under agent:<type>:)
 	_, err = rdb.Do(ctx, "ACL", "SETUSER", valkeyUser,
 		"on",
 		">"+password,
-		"~agent:"+agent.Spec.Type+":*",
+		"~"+wire.KeyPattern(agent.Spec.Type),
 		"~system:*",
 		"+@all",
 	).Result()
@@ -536,7 +698,7 @@ func (r *AgentReconciler) provisionValkeyCredentials(ctx context.Context, agent
 	log.Info("Valkey user created/updated with ACL", "user", valkeyUser, "acl", map[string]interface{}{
 		"on":           true,
 		"password":     "****",
-		"key_patterns": []string{agent.Spec.Type + ":*", "system:*"},
+		"key_patterns": []string{wire.KeyPattern(agent.Spec.Type), "system:*"},
 		"commands":     "+@all",
 	})
 
@@ -727,7 +889,7 @@ func (r *AgentReconciler) provisionPostgresCredentials(ctx context.Context, agen
 	log.Info("Granting USAGE on public schema", "RoleName", dbUsername)
 	_, err = tx.ExecContext(ctx, fmt.Sprintf("GRANT USAGE ON SCHEMA public TO %s", dbUsername))
 	if err != nil {
-		return "", fmt.Errorf("failed to grant usage on schema public to %s: %w", err)
+		return "", fmt.Errorf("failed to grant usage on schema public to %s: %w", dbUsername, err)
 	}
 
 	// Grant specific permissions on common tables/sequences in 'public' schema (DEFINE THESE!)
@@ -880,6 +1042,8 @@ func ensureAgentTables(db *sql.DB, log logr.Logger) error {
 			payload JSONB,
 			timestamp TIMESTAMPTZ
 		)`,
+			`CREATE INDEX IF NOT EXISTS agent_message_log_agent_id_timestamp_idx
+			ON public.agent_message_log (agent_id, timestamp)`,
 			`CREATE TABLE IF NOT EXISTS public.agent_status (
 			agent_id TEXT PRIMARY KEY,
 			phase TEXT,
@@ -940,6 +1104,10 @@ func (r *AgentReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&agentsv1alpha1.Agent{}).
 		Owns(&corev1.Pod{}). // Watch Pods owned by Agent CRs
+		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.StatefulSet{}).
+		Owns(&batchv1.Job{}).
+		Owns(&corev1.Service{}).
 		Named("agent").
 		Complete(r)
 }
@@ -0,0 +1,100 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+)
+
+// Defaults of the restart policy, used for the fields of agents that do not
+// set them
+const (
+	defaultRestartInitialDelay = 5 * time.Second
+	defaultRestartMaxDelay     = 5 * time.Minute
+	defaultRestartMultiplier   = 2.0
+	defaultRestartResetAfter   = 10 * time.Minute
+
+	// restartJitter is the fraction of a restart delay that is randomized
+	restartJitter = 0.2
+)
+
+// restartBackoff is the restart policy of an agent with defaults applied
+type restartBackoff struct {
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	multiplier   float64
+	resetAfter   time.Duration
+}
+
+// restartBackoffOf returns the restart policy of an agent
+func restartBackoffOf(agent *agentsv1alpha1.Agent) restartBackoff {
+	b := restartBackoff{
+		initialDelay: defaultRestartInitialDelay,
+		maxDelay:     defaultRestartMaxDelay,
+		multiplier:   defaultRestartMultiplier,
+		resetAfter:   defaultRestartResetAfter,
+	}
+	policy := agent.Spec.RestartPolicy
+	if policy == nil {
+		return b
+	}
+	if policy.InitialDelay != nil && policy.InitialDelay.Duration > 0 {
+		b.initialDelay = policy.InitialDelay.Duration
+	}
+	if policy.MaxDelay != nil && policy.MaxDelay.Duration > 0 {
+		b.maxDelay = policy.MaxDelay.Duration
+	}
+	if m, err := strconv.ParseFloat(policy.Multiplier, 64); err == nil && m >= 1 {
+		b.multiplier = m
+	}
+	if policy.ResetAfter != nil && policy.ResetAfter.Duration > 0 {
+		b.resetAfter = policy.ResetAfter.Duration
+	}
+	if b.maxDelay < b.initialDelay {
+		b.maxDelay = b.initialDelay
+	}
+	return b
+}
+
+// delay returns how long to wait before restarting a pod that has been
+// restarted the given number of times in a row. The delay is reduced by a
+// random fraction of up to restartJitter, so it never exceeds maxDelay.
+func (b restartBackoff) delay(restarts int) time.Duration {
+	d := float64(b.initialDelay) * math.Pow(b.multiplier, float64(restarts))
+	if d > float64(b.maxDelay) || math.IsInf(d, 0) || math.IsNaN(d) {
+		d = float64(b.maxDelay)
+	}
+	return time.Duration(d * (1 - restartJitter*rand.Float64()))
+}
+
+// healthySince returns when the agent last became ready, or the zero time if
+// it is not ready
+func healthySince(agent *agentsv1alpha1.Agent) time.Time {
+	ready := meta.FindStatusCondition(agent.Status.Conditions, agentsv1alpha1.ConditionReady)
+	if ready == nil || ready.Status != metav1.ConditionTrue {
+		return time.Time{}
+	}
+	return ready.LastTransitionTime.Time
+}
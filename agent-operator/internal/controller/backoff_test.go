@@ -0,0 +1,127 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+)
+
+func TestRestartBackoffDelay(t *testing.T) {
+	b := restartBackoff{initialDelay: 5 * time.Second, maxDelay: time.Minute, multiplier: 2}
+	tests := []struct {
+		name     string
+		backoff  restartBackoff
+		restarts int
+		want     time.Duration
+	}{
+		{name: "first restart", backoff: b, restarts: 0, want: 5 * time.Second},
+		{name: "grows by the multiplier", backoff: b, restarts: 2, want: 20 * time.Second},
+		{name: "capped", backoff: b, restarts: 5, want: time.Minute},
+		{name: "overflows to infinity", backoff: b, restarts: math.MaxInt32, want: time.Minute},
+		{
+			name:     "infinite multiplier",
+			backoff:  restartBackoff{initialDelay: time.Second, maxDelay: time.Minute, multiplier: math.Inf(1)},
+			restarts: 1,
+			want:     time.Minute,
+		},
+		{
+			name:     "not a number",
+			backoff:  restartBackoff{initialDelay: time.Second, maxDelay: time.Minute, multiplier: math.NaN()},
+			restarts: 1,
+			want:     time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The jitter only ever shortens the delay, by up to restartJitter
+			low := time.Duration(float64(tt.want) * (1 - restartJitter))
+			for i := 0; i < 100; i++ {
+				got := tt.backoff.delay(tt.restarts)
+				if got > tt.want || got < low {
+					t.Fatalf("delay(%d) = %v, want within [%v, %v]", tt.restarts, got, low, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRestartBackoffOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *agentsv1alpha1.RestartPolicy
+		want   restartBackoff
+	}{
+		{
+			name: "defaults",
+			want: restartBackoff{
+				initialDelay: defaultRestartInitialDelay,
+				maxDelay:     defaultRestartMaxDelay,
+				multiplier:   defaultRestartMultiplier,
+				resetAfter:   defaultRestartResetAfter,
+			},
+		},
+		{
+			name: "custom",
+			policy: &agentsv1alpha1.RestartPolicy{
+				InitialDelay: &metav1.Duration{Duration: time.Second},
+				MaxDelay:     &metav1.Duration{Duration: time.Minute},
+				Multiplier:   "1.5",
+				ResetAfter:   &metav1.Duration{Duration: time.Hour},
+			},
+			want: restartBackoff{initialDelay: time.Second, maxDelay: time.Minute, multiplier: 1.5, resetAfter: time.Hour},
+		},
+		{
+			name: "invalid values fall back to defaults",
+			policy: &agentsv1alpha1.RestartPolicy{
+				InitialDelay: &metav1.Duration{Duration: -time.Second},
+				Multiplier:   "0.5",
+			},
+			want: restartBackoff{
+				initialDelay: defaultRestartInitialDelay,
+				maxDelay:     defaultRestartMaxDelay,
+				multiplier:   defaultRestartMultiplier,
+				resetAfter:   defaultRestartResetAfter,
+			},
+		},
+		{
+			name: "max delay below initial delay",
+			policy: &agentsv1alpha1.RestartPolicy{
+				InitialDelay: &metav1.Duration{Duration: 10 * time.Minute},
+			},
+			want: restartBackoff{
+				initialDelay: 10 * time.Minute,
+				maxDelay:     10 * time.Minute,
+				multiplier:   defaultRestartMultiplier,
+				resetAfter:   defaultRestartResetAfter,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := &agentsv1alpha1.Agent{Spec: agentsv1alpha1.AgentSpec{RestartPolicy: tt.policy}}
+			if got := restartBackoffOf(agent); got != tt.want {
+				t.Fatalf("restartBackoffOf = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+	"github.com/Algoluna/agent-operator/pkg/wire"
+)
+
+const (
+	// defaultClaimIdleSeconds is used when spec.messaging.claimIdleSeconds is unset
+	defaultClaimIdleSeconds = 300
+	// defaultMaxDeliveries is used when spec.messaging.maxDeliveries is unset
+	defaultMaxDeliveries = 5
+)
+
+// valkeyAddress returns the cluster-wide host name and port of the Valkey service
+func valkeyAddress() (string, string) {
+	valkeyNamespace := os.Getenv("VALKEY_NAMESPACE")
+	if valkeyNamespace == "" {
+		valkeyNamespace = "agentbox-system"
+	}
+	valkeyServiceName := os.Getenv("VALKEY_SERVICE_NAME")
+	if valkeyServiceName == "" {
+		valkeyServiceName = "agentbox-valkey"
+	}
+	valkeyPort := os.Getenv("VALKEY_PORT")
+	if valkeyPort == "" {
+		valkeyPort = "6379"
+	}
+	return fmt.Sprintf("%s.%s.svc.cluster.local", valkeyServiceName, valkeyNamespace), valkeyPort
+}
+
+// newValkeyAdminClient connects to Valkey with the operator's admin credentials.
+// The caller must close the returned client.
+func newValkeyAdminClient(ctx context.Context) (*redis.Client, error) {
+	// Valkey admin credentials (must be set as env vars or via secret)
+	valkeyAdminUser := os.Getenv("VALKEY_ADMIN_USER")
+	if valkeyAdminUser == "" {
+		valkeyAdminUser = "default"
+	}
+	valkeyAdminPassword := os.Getenv("VALKEY_ADMIN_PASSWORD")
+	if valkeyAdminPassword == "" {
+		return nil, fmt.Errorf("VALKEY_ADMIN_PASSWORD must be set in the controller environment")
+	}
+
+	host, port := valkeyAddress()
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", host, port),
+		Username: valkeyAdminUser,
+		Password: valkeyAdminPassword,
+	})
+
+	// Test connection
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		rdb.Close()
+		return nil, fmt.Errorf("failed to connect to Valkey as admin: %w", err)
+	}
+	return rdb, nil
+}
+
+// ensureInboxGroup creates the consumer group agents read their inbox through,
+// creating the inbox stream if needed. Messages already in the inbox are
+// delivered to the group.
+func (r *AgentReconciler) ensureInboxGroup(ctx context.Context, agent *agentsv1alpha1.Agent) error {
+	log := logf.FromContext(ctx).WithValues("agent", agent.Name, "namespace", agent.Namespace)
+
+	rdb, err := newValkeyAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer rdb.Close()
+
+	inbox := wire.InboxKey(agent.Spec.Type, agent.Name)
+	err = rdb.XGroupCreateMkStream(ctx, inbox, wire.InboxGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group on %s: %w", inbox, err)
+	}
+	if err == nil {
+		log.Info("Created inbox consumer group", "stream", inbox, "group", wire.InboxGroup)
+	}
+	return nil
+}
+
+// claimIdleSeconds returns how long an inbox message may stay pending with an
+// unresponsive consumer before it is reclaimed
+func claimIdleSeconds(agent *agentsv1alpha1.Agent) int64 {
+	if agent.Spec.Messaging != nil && agent.Spec.Messaging.ClaimIdleSeconds > 0 {
+		return agent.Spec.Messaging.ClaimIdleSeconds
+	}
+	return defaultClaimIdleSeconds
+}
+
+// maxDeliveries returns how many times an inbox message may be delivered
+// before it is dead-lettered
+func maxDeliveries(agent *agentsv1alpha1.Agent) int64 {
+	if agent.Spec.Messaging != nil && agent.Spec.Messaging.MaxDeliveries > 0 {
+		return agent.Spec.Messaging.MaxDeliveries
+	}
+	return defaultMaxDeliveries
+}
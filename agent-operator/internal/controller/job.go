@@ -0,0 +1,236 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+)
+
+// workloadKindJob is what runOnce agents run as. It cannot be selected
+// through spec.workloadKind.
+const workloadKindJob agentsv1alpha1.WorkloadKind = "Job"
+
+// reconcileJob creates the Job a runOnce Agent runs as and reports its
+// progress: the pods that succeeded and failed, and the exit code and
+// termination message of the pod that terminated last. A runOnce Agent is
+// only ever started once: after its Job is deleted, e.g. by its TTL, the
+// Agent keeps its final status.
+func (r *AgentReconciler) reconcileJob(ctx context.Context, agent *agentsv1alpha1.Agent, observed *agentsv1alpha1.AgentStatus,
+	postgresSecretName, valkeySecretName string) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	key := types.NamespacedName{Name: agentWorkloadName(agent), Namespace: agent.Namespace}
+	var job batchv1.Job
+	err := r.Get(ctx, key, &job)
+	if apierrors.IsNotFound(err) && agent.Status.JobUID != "" {
+		// The cache may not have seen a Job created moments ago yet; only
+		// the API server can tell that a started Job is gone
+		err = r.APIReader.Get(ctx, key, &job)
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, "Failed to get Job")
+		return ctrl.Result{}, err
+	}
+	if apierrors.IsNotFound(err) {
+		// A started Job may have been deleted after its TTL, possibly before
+		// its completion was seen; the run is over either way
+		if agent.Status.JobUID != "" || agent.Status.Phase == PhaseCompleted || agent.Status.Phase == PhaseFailed {
+			phase, message := agent.Status.Phase, agent.Status.Message
+			if phase != PhaseCompleted && phase != PhaseFailed {
+				phase, message = jobGoneOutcome(agent)
+			}
+			setNotReady(agent, ReasonPodGone, fmt.Sprintf("Agent is %s and its Job is gone", phase))
+			agent.Status.Phase = phase
+			agent.Status.Message = message
+			if !equality.Semantic.DeepEqual(*observed, agent.Status) {
+				return r.updateAgentStatus(ctx, agent, phase, message)
+			}
+			return ctrl.Result{}, nil
+		}
+
+		// Make sure the inbox can be consumed through its consumer group
+		// before the agent starts reading it
+		if err := r.ensureInboxGroup(ctx, agent); err != nil {
+			log.Error(err, "Failed to provision inbox consumer group")
+			setNotReady(agent, ReasonPodCreating, fmt.Sprintf("Failed to provision inbox: %v", err))
+			_, statusErr := r.updateAgentStatus(ctx, agent, PhasePending, fmt.Sprintf("Failed to provision inbox: %v", err))
+			return ctrl.Result{RequeueAfter: time.Second * 30}, statusErr
+		}
+		newJob := r.constructJobForAgent(agent, postgresSecretName, valkeySecretName)
+		if err := r.Create(ctx, newJob); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				// The cache has not seen the Job yet, e.g. one created by a
+				// concurrent reconcile or before its UID could be recorded
+				log.Info("Job already exists, waiting for it to be cached", "Job.Name", newJob.Name)
+				return ctrl.Result{RequeueAfter: time.Second * 2}, nil
+			}
+			log.Error(err, "Failed to create Job for Agent", "Job.Namespace", newJob.Namespace, "Job.Name", newJob.Name)
+			if !apierrors.IsInvalid(err) && !apierrors.IsBadRequest(err) {
+				// Retry rather than fail the Agent, which is never started again
+				return ctrl.Result{}, err
+			}
+			msg := fmt.Sprintf("Failed to create job: %v", err)
+			setCondition(agent, agentsv1alpha1.ConditionPodScheduled, metav1.ConditionFalse, ReasonPodCreateFailed, msg)
+			setNotReady(agent, ReasonPodCreateFailed, msg)
+			setDegraded(agent, ReasonPodCreateFailed, msg)
+			return r.updateAgentStatus(ctx, agent, PhaseFailed, msg)
+		}
+		log.Info("Created Job for Agent", "Job.Namespace", newJob.Namespace, "Job.Name", newJob.Name)
+		agent.Status.JobUID = newJob.UID
+		agent.Status.PodName = ""
+		agent.Status.StartTime = nil
+		agent.Status.CompletionTime = nil
+		agent.Status.ExitCode = nil
+		agent.Status.TerminationMessage = ""
+		setCondition(agent, agentsv1alpha1.ConditionPodScheduled, metav1.ConditionFalse, ReasonPodCreating, "Job created, waiting for its pod to be scheduled")
+		setNotReady(agent, ReasonPodCreating, "Job created, waiting for its pod to start")
+		return r.updateAgentStatus(ctx, agent, PhasePending, "Job created, waiting for its pod to start")
+	}
+
+	// --- Job Exists ---
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(agent.Namespace), client.MatchingLabels(agentLabels(agent))); err != nil {
+		log.Error(err, "Failed to list agent pods")
+		return ctrl.Result{}, err
+	}
+	if pod := newestPod(pods.Items); pod != nil {
+		setPodStatus(agent, pod)
+	}
+	if t := lastTermination(pods.Items); t != nil {
+		agent.Status.ExitCode = &t.ExitCode
+		agent.Status.TerminationMessage = t.Message
+	}
+	agent.Status.JobUID = job.UID
+	agent.Status.StartTime = job.Status.StartTime
+	agent.Status.SucceededPods = job.Status.Succeeded
+	agent.Status.FailedPods = job.Status.Failed
+
+	phase := PhasePending
+	message := "Agent job is waiting for its pod to start"
+	if job.Status.Active > 0 {
+		phase = PhaseRunning
+		message = fmt.Sprintf("Agent job is running (%d active, %d succeeded, %d failed)", job.Status.Active, job.Status.Succeeded, job.Status.Failed)
+	}
+	setHealthy(agent)
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			phase = PhaseCompleted
+			message = fmt.Sprintf("Agent job completed successfully (%d succeeded)", job.Status.Succeeded)
+			agent.Status.CompletionTime = job.Status.CompletionTime
+			setNotReady(agent, ReasonCompleted, fmt.Sprintf("Job %s has completed", job.Name))
+		case batchv1.JobFailed:
+			phase = PhaseFailed
+			message = fmt.Sprintf("Agent job failed: %s", c.Message)
+			agent.Status.LastFailureReason = c.Reason
+			setNotReady(agent, ReasonPodFailed, fmt.Sprintf("Job %s has failed", job.Name))
+			setDegraded(agent, c.Reason, message)
+		}
+	}
+
+	agent.Status.Phase = phase
+	agent.Status.Message = message
+	if !equality.Semantic.DeepEqual(*observed, agent.Status) || agent.Status.ObservedGeneration != agent.Generation {
+		return r.updateAgentStatus(ctx, agent, phase, message)
+	}
+	return ctrl.Result{}, nil
+}
+
+// jobGoneOutcome returns the phase and message of a runOnce Agent whose Job
+// was deleted before it was seen to finish, judging by the exit code of its
+// pod if that was seen
+func jobGoneOutcome(agent *agentsv1alpha1.Agent) (string, string) {
+	switch {
+	case agent.Status.ExitCode == nil:
+		return PhaseFailed, "Agent job was deleted before it was seen to finish"
+	case *agent.Status.ExitCode == 0:
+		return PhaseCompleted, "Agent job completed successfully, it was deleted before its completion was seen"
+	default:
+		return PhaseFailed, fmt.Sprintf("Agent job failed with exit code %d, it was deleted before its failure was seen", *agent.Status.ExitCode)
+	}
+}
+
+// constructJobForAgent creates a Job object for the given runOnce Agent
+func (r *AgentReconciler) constructJobForAgent(agent *agentsv1alpha1.Agent, postgresSecretName, valkeySecretName string) *batchv1.Job {
+	log := logf.Log.WithValues("agent", agent.Name, "namespace", agent.Namespace)
+
+	template := podTemplateForAgent(agent, postgresSecretName, valkeySecretName, corev1.RestartPolicyNever)
+	// Surface the end of the log of a pod that fails without writing a
+	// termination message
+	template.Spec.Containers[0].TerminationMessagePolicy = corev1.TerminationMessageFallbackToLogsOnError
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      agentWorkloadName(agent),
+			Namespace: agent.Namespace,
+			Labels:    agentLabels(agent),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To[int32](0),
+			Template:     template,
+		},
+	}
+	if spec := agent.Spec.Job; spec != nil {
+		if spec.BackoffLimit != nil {
+			job.Spec.BackoffLimit = spec.BackoffLimit
+		}
+		job.Spec.ActiveDeadlineSeconds = spec.ActiveDeadlineSeconds
+		job.Spec.TTLSecondsAfterFinished = spec.TTLSecondsAfterFinished
+		job.Spec.Parallelism = spec.Parallelism
+		job.Spec.Completions = spec.Completions
+	}
+
+	// Set Agent instance as the owner and controller
+	if err := controllerutil.SetControllerReference(agent, job, r.Scheme); err != nil {
+		log.Error(err, "Failed to set controller reference on job")
+	}
+	return job
+}
+
+// lastTermination returns the state of the agent container that terminated
+// last among the given pods, or nil if none has terminated
+func lastTermination(pods []corev1.Pod) *corev1.ContainerStateTerminated {
+	var last *corev1.ContainerStateTerminated
+	for i := range pods {
+		for _, cs := range pods[i].Status.ContainerStatuses {
+			if t := cs.State.Terminated; cs.Name == "agent" && t != nil && (last == nil || last.FinishedAt.Before(&t.FinishedAt)) {
+				last = t
+			}
+		}
+	}
+	return last
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+)
+
+func TestReconcileStartedJob(t *testing.T) {
+	tests := []struct {
+		name      string
+		exitCode  *int32
+		apiJob    bool
+		wantPhase string
+	}{
+		{name: "job not cached yet", apiJob: true, wantPhase: PhaseRunning},
+		{name: "job deleted", wantPhase: PhaseFailed},
+		{name: "job deleted after its pod succeeded", exitCode: ptr.To[int32](0), wantPhase: PhaseCompleted},
+		{name: "job deleted after its pod failed", exitCode: ptr.To[int32](1), wantPhase: PhaseFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := &agentsv1alpha1.Agent{
+				ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "agent-hello"},
+				Spec:       agentsv1alpha1.AgentSpec{RunOnce: true},
+				Status: agentsv1alpha1.AgentStatus{
+					Phase:    PhasePending,
+					JobUID:   "job-uid",
+					ExitCode: tt.exitCode,
+				},
+			}
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: agentWorkloadName(agent), Namespace: agent.Namespace, UID: "job-uid"},
+				Status:     batchv1.JobStatus{Active: 1},
+			}
+
+			// The cache only holds the Agent; the API server may also hold
+			// its Job
+			r := newTestReconciler(t, agent.DeepCopy())
+			apiObjs := []client.Object{agent.DeepCopy()}
+			if tt.apiJob {
+				apiObjs = append(apiObjs, job)
+			}
+			r.APIReader = newTestReconciler(t, apiObjs...).Client
+
+			observed := agent.Status.DeepCopy()
+			if _, err := r.reconcileJob(context.Background(), agent, observed, "", ""); err != nil {
+				t.Fatalf("reconcileJob: %v", err)
+			}
+			var stored agentsv1alpha1.Agent
+			if err := r.Get(context.Background(), client.ObjectKeyFromObject(agent), &stored); err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status.Phase != tt.wantPhase {
+				t.Fatalf("phase = %s (%s), want %s", stored.Status.Phase, stored.Status.Message, tt.wantPhase)
+			}
+			if stored.Status.JobUID != "job-uid" {
+				t.Fatalf("job UID = %q, want job-uid", stored.Status.JobUID)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+)
+
+const (
+	// specHashAnnotation records the hash of the pod template an agent's pod
+	// or workload was created from, to detect when the spec has drifted
+	specHashAnnotation = "agents.algoluna.com/spec-hash"
+
+	// specHashLength is how many hex digits of the hash are kept
+	specHashLength = 10
+
+	// ReasonRolloutFailed reports a replacement pod that failed while the
+	// outdated pod kept serving
+	ReasonRolloutFailed = "RolloutFailed"
+)
+
+// withSpecHash annotates a pod template with the hash of its content
+func withSpecHash(template corev1.PodTemplateSpec) corev1.PodTemplateSpec {
+	template = *template.DeepCopy()
+	delete(template.Annotations, specHashAnnotation)
+	data, err := json.Marshal(template)
+	if err != nil {
+		// A pod template always marshals
+		panic(err)
+	}
+	sum := sha256.Sum256(data)
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[specHashAnnotation] = hex.EncodeToString(sum[:])[:specHashLength]
+	return template
+}
+
+// specHash returns the spec hash an object was annotated with
+func specHash(obj metav1.Object) string {
+	return obj.GetAnnotations()[specHashAnnotation]
+}
+
+// updateStrategy returns the update strategy of an agent with defaults
+// applied
+func updateStrategy(agent *agentsv1alpha1.Agent) (agentsv1alpha1.UpdateStrategyType, int32) {
+	strategy := agentsv1alpha1.UpdateStrategyRollingUpdate
+	var maxUnavailable int32
+	if s := agent.Spec.UpdateStrategy; s != nil {
+		if s.Type != "" {
+			strategy = s.Type
+		}
+		if s.MaxUnavailable != nil {
+			maxUnavailable = *s.MaxUnavailable
+		}
+	}
+	return strategy, maxUnavailable
+}
+
+// listAgentPods returns the bare pods of an agent that are not being
+// deleted, newest first
+func (r *AgentReconciler) listAgentPods(ctx context.Context, agent *agentsv1alpha1.Agent) ([]corev1.Pod, error) {
+	var list corev1.PodList
+	if err := r.List(ctx, &list, client.InNamespace(agent.Namespace), client.MatchingLabels(agentLabels(agent))); err != nil {
+		return nil, err
+	}
+	pods := make([]corev1.Pod, 0, len(list.Items))
+	for _, pod := range list.Items {
+		if pod.DeletionTimestamp == nil && metav1.IsControlledBy(&pod, agent) {
+			pods = append(pods, pod)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[j].CreationTimestamp.Before(&pods[i].CreationTimestamp)
+	})
+	return pods, nil
+}
+
+// adoptLegacyPod annotates a pod created before pods carried a spec hash
+// with the desired hash if its image and environment match the template, so
+// upgrading the operator does not restart every agent
+func (r *AgentReconciler) adoptLegacyPod(ctx context.Context, pod *corev1.Pod, template corev1.PodTemplateSpec) error {
+	if specHash(pod) != "" || len(pod.Spec.Containers) == 0 {
+		return nil
+	}
+	have, want := pod.Spec.Containers[0], template.Spec.Containers[0]
+	if have.Image != want.Image || !equality.Semantic.DeepEqual(have.Env, want.Env) {
+		return nil
+	}
+	patch := client.MergeFrom(pod.DeepCopy())
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[specHashAnnotation] = specHash(&template)
+	return r.Patch(ctx, pod, patch)
+}
+
+// podRollout is the state of the pods of an agent with respect to its spec
+type podRollout struct {
+	// current is the newest pod running the desired spec, if any
+	current *corev1.Pod
+	// outdated are the pods running an earlier spec
+	outdated []corev1.Pod
+}
+
+// splitPods sorts the pods of an agent by whether they run its desired spec
+func splitPods(pods []corev1.Pod, hash string) podRollout {
+	var rollout podRollout
+	for i := range pods {
+		if specHash(&pods[i]) == hash && rollout.current == nil {
+			rollout.current = &pods[i]
+			continue
+		}
+		rollout.outdated = append(rollout.outdated, pods[i])
+	}
+	return rollout
+}
+
+// rolloutPods replaces the outdated pods of a long-running agent according
+// to its update strategy. It returns the pod the rest of the reconciliation
+// should look at, or nil if a new one has to be created. While the rollout
+// waits for a replacement pod to become ready it also returns a message
+// describing it, and the pod returned is the outdated one still serving.
+func (r *AgentReconciler) rolloutPods(ctx context.Context, agent *agentsv1alpha1.Agent, pods []corev1.Pod, template corev1.PodTemplateSpec,
+	create func() (*corev1.Pod, error)) (*corev1.Pod, string, error) {
+	log := logf.FromContext(ctx)
+	rollout := splitPods(pods, specHash(&template))
+	if len(rollout.outdated) == 0 {
+		return rollout.current, "", nil
+	}
+
+	// Keep an outdated pod serving until its replacement is ready, unless
+	// the strategy allows the agent to be unavailable
+	strategy, maxUnavailable := updateStrategy(agent)
+	var serving *corev1.Pod
+	for i := range rollout.outdated {
+		if serving == nil && podReady(&rollout.outdated[i]) {
+			serving = &rollout.outdated[i]
+		}
+	}
+	surge := strategy == agentsv1alpha1.UpdateStrategyRollingUpdate && maxUnavailable == 0 && serving != nil
+	if surge && (rollout.current == nil || !podReady(rollout.current)) {
+		if rollout.current == nil {
+			pod, err := create()
+			if err != nil {
+				return nil, "", err
+			}
+			log.Info("Created replacement Pod for Agent spec change", "Pod.Name", pod.Name)
+			rollout.current = pod
+		}
+		if rollout.current.Status.Phase == corev1.PodFailed || rollout.current.Status.Phase == corev1.PodSucceeded {
+			// Keep the outdated pod serving and the failed replacement for
+			// inspection until the spec changes again
+			msg := fmt.Sprintf("Replacement pod %s for the spec change failed: %s", rollout.current.Name, podFailureReason(rollout.current))
+			setDegraded(agent, ReasonRolloutFailed, msg)
+			return serving, msg, nil
+		}
+		setHealthy(agent)
+		return serving, fmt.Sprintf("Rolling out spec change, waiting for replacement pod %s to become ready", rollout.current.Name), nil
+	}
+
+	for i := range rollout.outdated {
+		pod := &rollout.outdated[i]
+		log.Info("Deleting Pod outdated by an Agent spec change", "Pod.Name", pod.Name, "strategy", strategy)
+		if err := r.Delete(ctx, pod); err != nil && !apierrors.IsNotFound(err) {
+			return nil, "", err
+		}
+	}
+	return rollout.current, "", nil
+}
+
+// podReady reports whether a pod is running and ready
+func podReady(pod *corev1.Pod) bool {
+	ready := podCondition(pod, corev1.PodReady)
+	return pod.Status.Phase == corev1.PodRunning && ready != nil && ready.Status == corev1.ConditionTrue
+}
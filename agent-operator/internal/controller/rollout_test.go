@@ -0,0 +1,282 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+)
+
+// newTestReconciler returns a reconciler backed by a fake client holding objs
+func newTestReconciler(t *testing.T, objs ...client.Object) *AgentReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := agentsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return &AgentReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+			WithStatusSubresource(&agentsv1alpha1.Agent{}).Build(),
+		Scheme: scheme,
+	}
+}
+
+func testTemplate(image string) corev1.PodTemplateSpec {
+	return withSpecHash(corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "agent",
+				Image: image,
+				Env:   []corev1.EnvVar{{Name: "AGENT_NAME", Value: "hello"}},
+			}},
+		},
+	})
+}
+
+// testPod returns a pod created at the given offset from a fixed time,
+// running the given template
+func testPod(name string, created time.Duration, template corev1.PodTemplateSpec, ready bool) corev1.Pod {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "agent-hello",
+			CreationTimestamp: metav1.NewTime(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Add(created)),
+			Annotations:       map[string]string{specHashAnnotation: specHash(&template)},
+		},
+		Spec: template.Spec,
+	}
+	if ready {
+		pod.Status.Phase = corev1.PodRunning
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	}
+	return pod
+}
+
+func TestWithSpecHash(t *testing.T) {
+	template := testTemplate("hello:v1")
+	hash := specHash(&template)
+	if len(hash) != specHashLength {
+		t.Fatalf("hash %q is not %d digits long", hash, specHashLength)
+	}
+
+	// Hashing again ignores the hash already present
+	if again := withSpecHash(template); specHash(&again) != hash {
+		t.Fatalf("rehashing changed the hash from %s to %s", hash, specHash(&again))
+	}
+
+	if other := testTemplate("hello:v2"); specHash(&other) == hash {
+		t.Fatal("a different image did not change the hash")
+	}
+
+	// The template passed in is left alone
+	plain := corev1.PodTemplateSpec{Spec: template.Spec}
+	withSpecHash(plain)
+	if plain.Annotations != nil {
+		t.Fatalf("withSpecHash modified its argument: %v", plain.Annotations)
+	}
+}
+
+func TestSplitPods(t *testing.T) {
+	v1, v2 := testTemplate("hello:v1"), testTemplate("hello:v2")
+	pods := []corev1.Pod{
+		testPod("newest", 3*time.Minute, v2, false),
+		testPod("new", 2*time.Minute, v2, true),
+		testPod("old", time.Minute, v1, true),
+	}
+
+	rollout := splitPods(pods, specHash(&v2))
+	if rollout.current == nil || rollout.current.Name != "newest" {
+		t.Fatalf("current = %v, want newest", rollout.current)
+	}
+	if len(rollout.outdated) != 2 || rollout.outdated[0].Name != "new" || rollout.outdated[1].Name != "old" {
+		t.Fatalf("unexpected outdated pods %v", rollout.outdated)
+	}
+
+	if rollout := splitPods(pods[2:], specHash(&v2)); rollout.current != nil || len(rollout.outdated) != 1 {
+		t.Fatalf("unexpected rollout %+v", rollout)
+	}
+}
+
+func TestAdoptLegacyPod(t *testing.T) {
+	template := testTemplate("hello:v1")
+	tests := []struct {
+		name      string
+		image     string
+		wantAdopt bool
+	}{
+		{name: "matching pod", image: "hello:v1", wantAdopt: true},
+		{name: "different image", image: "hello:v0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := testPod("legacy", 0, testTemplate(tt.image), true)
+			delete(pod.Annotations, specHashAnnotation)
+			r := newTestReconciler(t, &pod)
+
+			if err := r.adoptLegacyPod(context.Background(), &pod, template); err != nil {
+				t.Fatalf("adoptLegacyPod: %v", err)
+			}
+			var stored corev1.Pod
+			if err := r.Get(context.Background(), client.ObjectKeyFromObject(&pod), &stored); err != nil {
+				t.Fatal(err)
+			}
+			if adopted := specHash(&stored) == specHash(&template); adopted != tt.wantAdopt {
+				t.Fatalf("adopted = %v, want %v", adopted, tt.wantAdopt)
+			}
+		})
+	}
+}
+
+func TestRolloutPods(t *testing.T) {
+	v1, v2 := testTemplate("hello:v1"), testTemplate("hello:v2")
+	tests := []struct {
+		name        string
+		strategy    *agentsv1alpha1.UpdateStrategy
+		pods        []corev1.Pod
+		replacement *corev1.Pod
+		wantPod     string
+		wantCreate  bool
+		wantMessage bool
+		wantDeleted []string
+		wantDegrade bool
+	}{
+		{
+			name:    "up to date",
+			pods:    []corev1.Pod{testPod("current", 0, v2, true)},
+			wantPod: "current",
+		},
+		{
+			name:        "surge while the outdated pod serves",
+			pods:        []corev1.Pod{testPod("old", 0, v1, true)},
+			replacement: ptr.To(testPod("new", time.Minute, v2, false)),
+			wantPod:     "old",
+			wantCreate:  true,
+			wantMessage: true,
+		},
+		{
+			name:        "wait for replacement to become ready",
+			pods:        []corev1.Pod{testPod("new", time.Minute, v2, false), testPod("old", 0, v1, true)},
+			wantPod:     "old",
+			wantMessage: true,
+		},
+		{
+			name:        "replace once the replacement is ready",
+			pods:        []corev1.Pod{testPod("new", time.Minute, v2, true), testPod("old", 0, v1, true)},
+			wantPod:     "new",
+			wantDeleted: []string{"old"},
+		},
+		{
+			name:        "recreate",
+			strategy:    &agentsv1alpha1.UpdateStrategy{Type: agentsv1alpha1.UpdateStrategyRecreate},
+			pods:        []corev1.Pod{testPod("old", 0, v1, true)},
+			wantDeleted: []string{"old"},
+		},
+		{
+			name:        "rolling update allowed to be unavailable",
+			strategy:    &agentsv1alpha1.UpdateStrategy{MaxUnavailable: ptr.To[int32](1)},
+			pods:        []corev1.Pod{testPod("old", 0, v1, true)},
+			wantDeleted: []string{"old"},
+		},
+		{
+			name:        "outdated pod not serving",
+			pods:        []corev1.Pod{testPod("old", 0, v1, false)},
+			wantDeleted: []string{"old"},
+		},
+		{
+			name: "failed replacement",
+			pods: func() []corev1.Pod {
+				failed := testPod("new", time.Minute, v2, false)
+				failed.Status.Phase = corev1.PodFailed
+				return []corev1.Pod{failed, testPod("old", 0, v1, true)}
+			}(),
+			wantPod:     "old",
+			wantMessage: true,
+			wantDegrade: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := &agentsv1alpha1.Agent{
+				ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "agent-hello"},
+				Spec:       agentsv1alpha1.AgentSpec{UpdateStrategy: tt.strategy},
+			}
+			objs := make([]client.Object, 0, len(tt.pods))
+			for i := range tt.pods {
+				objs = append(objs, tt.pods[i].DeepCopy())
+			}
+			r := newTestReconciler(t, objs...)
+
+			created := false
+			create := func() (*corev1.Pod, error) {
+				created = true
+				if tt.replacement == nil {
+					t.Fatal("unexpected pod creation")
+				}
+				return tt.replacement, nil
+			}
+			pod, msg, err := r.rolloutPods(context.Background(), agent, tt.pods, v2, create)
+			if err != nil {
+				t.Fatalf("rolloutPods: %v", err)
+			}
+
+			gotPod := ""
+			if pod != nil {
+				gotPod = pod.Name
+			}
+			if gotPod != tt.wantPod {
+				t.Errorf("pod = %q, want %q", gotPod, tt.wantPod)
+			}
+			if created != tt.wantCreate {
+				t.Errorf("created = %v, want %v", created, tt.wantCreate)
+			}
+			if (msg != "") != tt.wantMessage {
+				t.Errorf("message = %q, want one: %v", msg, tt.wantMessage)
+			}
+			degraded := meta.IsStatusConditionTrue(agent.Status.Conditions, agentsv1alpha1.ConditionDegraded)
+			if degraded != tt.wantDegrade {
+				t.Errorf("degraded = %v, want %v", degraded, tt.wantDegrade)
+			}
+
+			deleted := map[string]bool{}
+			for _, name := range tt.wantDeleted {
+				deleted[name] = true
+			}
+			for _, p := range tt.pods {
+				err := r.Get(context.Background(), client.ObjectKeyFromObject(&p), &corev1.Pod{})
+				if gone := apierrors.IsNotFound(err); gone != deleted[p.Name] {
+					t.Errorf("pod %s deleted = %v, want %v", p.Name, gone, deleted[p.Name])
+				}
+			}
+		})
+	}
+}
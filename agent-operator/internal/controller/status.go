@@ -0,0 +1,167 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+)
+
+// Reasons of the conditions the controller sets
+const (
+	ReasonProvisioned         = "Provisioned"
+	ReasonProvisioningFailed  = "ProvisioningFailed"
+	ReasonPodCreating         = "PodCreating"
+	ReasonPodCreateFailed     = "PodCreateFailed"
+	ReasonScheduled           = "Scheduled"
+	ReasonPodPending          = "PodPending"
+	ReasonPodReady            = "PodReady"
+	ReasonPodNotReady         = "PodNotReady"
+	ReasonPodFailed           = "PodFailed"
+	ReasonPodGone             = "PodGone"
+	ReasonCompleted           = "Completed"
+	ReasonRestarting          = "Restarting"
+	ReasonMaxRestartsExceeded = "MaxRestartsExceeded"
+	ReasonAsExpected          = "AsExpected"
+	ReasonTTLScheduled        = "TTLScheduled"
+	ReasonNoTTL               = "NoTTL"
+)
+
+// setCondition sets a condition of the agent, stamping it with the
+// generation it was observed at. The transition time only changes along
+// with the status.
+func setCondition(agent *agentsv1alpha1.Agent, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&agent.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: agent.Generation,
+	})
+}
+
+// setTTLCondition reports when the agent expires unless it is active
+func setTTLCondition(agent *agentsv1alpha1.Agent, expiresAt time.Time) {
+	if agent.Spec.TTL <= 0 {
+		setCondition(agent, agentsv1alpha1.ConditionTTLExpiring, metav1.ConditionFalse, ReasonNoTTL, "Agent has no TTL")
+		return
+	}
+	setCondition(agent, agentsv1alpha1.ConditionTTLExpiring, metav1.ConditionTrue, ReasonTTLScheduled,
+		fmt.Sprintf("Agent is deleted at %s unless it is active", expiresAt.UTC().Format(time.RFC3339)))
+}
+
+// setPodStatus records the state of the agent's pod: its name and start
+// time, and whether it is scheduled and ready
+func setPodStatus(agent *agentsv1alpha1.Agent, pod *corev1.Pod) {
+	agent.Status.PodName = pod.Name
+	agent.Status.StartTime = pod.Status.StartTime
+
+	scheduled := podCondition(pod, corev1.PodScheduled)
+	switch {
+	case scheduled != nil && scheduled.Status == corev1.ConditionTrue:
+		setCondition(agent, agentsv1alpha1.ConditionPodScheduled, metav1.ConditionTrue, ReasonScheduled,
+			fmt.Sprintf("Pod %s is scheduled on node %s", pod.Name, pod.Spec.NodeName))
+	case scheduled != nil && scheduled.Reason != "":
+		setCondition(agent, agentsv1alpha1.ConditionPodScheduled, metav1.ConditionFalse, scheduled.Reason, scheduled.Message)
+	default:
+		setCondition(agent, agentsv1alpha1.ConditionPodScheduled, metav1.ConditionFalse, ReasonPodPending,
+			fmt.Sprintf("Pod %s is waiting to be scheduled", pod.Name))
+	}
+
+	ready := podCondition(pod, corev1.PodReady)
+	switch {
+	case pod.Status.Phase == corev1.PodRunning && ready != nil && ready.Status == corev1.ConditionTrue:
+		setCondition(agent, agentsv1alpha1.ConditionReady, metav1.ConditionTrue, ReasonPodReady,
+			fmt.Sprintf("Pod %s is running and ready", pod.Name))
+	case pod.Status.Phase == corev1.PodSucceeded && agent.Spec.RunOnce:
+		setCondition(agent, agentsv1alpha1.ConditionReady, metav1.ConditionFalse, ReasonCompleted,
+			fmt.Sprintf("Pod %s has completed", pod.Name))
+	case pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded:
+		setCondition(agent, agentsv1alpha1.ConditionReady, metav1.ConditionFalse, ReasonPodFailed,
+			fmt.Sprintf("Pod %s has exited", pod.Name))
+	case pod.Status.Phase == corev1.PodRunning:
+		setCondition(agent, agentsv1alpha1.ConditionReady, metav1.ConditionFalse, ReasonPodNotReady,
+			fmt.Sprintf("Pod %s is running but not ready", pod.Name))
+	default:
+		setCondition(agent, agentsv1alpha1.ConditionReady, metav1.ConditionFalse, ReasonPodPending,
+			fmt.Sprintf("Pod %s is %s", pod.Name, pod.Status.Phase))
+	}
+}
+
+// setNotReady reports that the agent has no pod that could serve it
+func setNotReady(agent *agentsv1alpha1.Agent, reason, message string) {
+	setCondition(agent, agentsv1alpha1.ConditionReady, metav1.ConditionFalse, reason, message)
+}
+
+// setDegraded reports that the agent is failing
+func setDegraded(agent *agentsv1alpha1.Agent, reason, message string) {
+	setCondition(agent, agentsv1alpha1.ConditionDegraded, metav1.ConditionTrue, reason, message)
+}
+
+// setHealthy reports that the agent is not failing
+func setHealthy(agent *agentsv1alpha1.Agent) {
+	setCondition(agent, agentsv1alpha1.ConditionDegraded, metav1.ConditionFalse, ReasonAsExpected, "Agent is not failing")
+}
+
+// podCondition returns the condition of the given type of a pod, or nil
+func podCondition(pod *corev1.Pod, conditionType corev1.PodConditionType) *corev1.PodCondition {
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == conditionType {
+			return &pod.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// podFailureReason explains why a pod stopped: the pod's own reason, such
+// as Evicted, or else the reason its agent container terminated with, such
+// as OOMKilled or Error
+func podFailureReason(pod *corev1.Pod) string {
+	if pod.Status.Reason != "" {
+		return pod.Status.Reason
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if t := cs.State.Terminated; t != nil && t.Reason != "" {
+			return t.Reason
+		}
+		if t := cs.LastTerminationState.Terminated; t != nil && t.Reason != "" {
+			return t.Reason
+		}
+	}
+	return ""
+}
+
+// podCompletionTime returns when the containers of a finished pod
+// terminated, or now if the pod does not say
+func podCompletionTime(pod *corev1.Pod) metav1.Time {
+	var finished metav1.Time
+	for _, cs := range pod.Status.ContainerStatuses {
+		if t := cs.State.Terminated; t != nil && finished.Before(&t.FinishedAt) {
+			finished = t.FinishedAt
+		}
+	}
+	if finished.IsZero() {
+		return metav1.Now()
+	}
+	return finished
+}
@@ -0,0 +1,351 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	agentsv1alpha1 "github.com/Algoluna/agent-operator/api/v1alpha1"
+)
+
+const (
+	// dataVolumeName is the name of the persistent volume of an agent run as
+	// a StatefulSet
+	dataVolumeName = "data"
+
+	// defaultDataMountPath is where the persistent volume is mounted unless
+	// the agent's storage says otherwise
+	defaultDataMountPath = "/data"
+
+	// ReasonProgressDeadlineExceeded reports a Deployment that failed to roll
+	// out its pod
+	ReasonProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+)
+
+// workloadKind returns what an Agent runs as. Run-once agents always run as
+// a Job.
+func workloadKind(agent *agentsv1alpha1.Agent) agentsv1alpha1.WorkloadKind {
+	if agent.Spec.RunOnce {
+		return workloadKindJob
+	}
+	if agent.Spec.WorkloadKind == "" {
+		return agentsv1alpha1.WorkloadKindPod
+	}
+	return agent.Spec.WorkloadKind
+}
+
+// reconcileWorkload creates or updates the Deployment or StatefulSet a
+// long-running Agent runs as, and reports the state of its pod. The
+// workload's controller restarts failed containers and rolls out changes to
+// the pod template, such as a new image.
+func (r *AgentReconciler) reconcileWorkload(ctx context.Context, agent *agentsv1alpha1.Agent, kind agentsv1alpha1.WorkloadKind,
+	observed *agentsv1alpha1.AgentStatus, postgresSecretName, valkeySecretName string) (ctrl.Result, error) {
+	log := logf.FromContext(ctx).WithValues("kind", kind)
+
+	var workload client.Object = &appsv1.Deployment{}
+	if kind == agentsv1alpha1.WorkloadKindStatefulSet {
+		workload = &appsv1.StatefulSet{}
+	}
+	err := r.Get(ctx, types.NamespacedName{Name: agentWorkloadName(agent), Namespace: agent.Namespace}, workload)
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, "Failed to get workload")
+		return ctrl.Result{}, err
+	}
+	if apierrors.IsNotFound(err) {
+		// Make sure the inbox can be consumed through its consumer group
+		// before the agent starts reading it
+		if err := r.ensureInboxGroup(ctx, agent); err != nil {
+			log.Error(err, "Failed to provision inbox consumer group")
+			setNotReady(agent, ReasonPodCreating, fmt.Sprintf("Failed to provision inbox: %v", err))
+			_, statusErr := r.updateAgentStatus(ctx, agent, PhasePending, fmt.Sprintf("Failed to provision inbox: %v", err))
+			return ctrl.Result{RequeueAfter: time.Second * 30}, statusErr
+		}
+	}
+
+	template := podTemplateForAgent(agent, postgresSecretName, valkeySecretName, corev1.RestartPolicyAlways)
+	var op controllerutil.OperationResult
+	switch w := workload.(type) {
+	case *appsv1.Deployment:
+		w.Name, w.Namespace = agentWorkloadName(agent), agent.Namespace
+		op, err = controllerutil.CreateOrUpdate(ctx, r.Client, w, func() error {
+			r.mutateDeployment(agent, w, template)
+			return controllerutil.SetControllerReference(agent, w, r.Scheme)
+		})
+	case *appsv1.StatefulSet:
+		if err = r.ensureHeadlessService(ctx, agent); err != nil {
+			break
+		}
+		w.Name, w.Namespace = agentWorkloadName(agent), agent.Namespace
+		op, err = controllerutil.CreateOrUpdate(ctx, r.Client, w, func() error {
+			r.mutateStatefulSet(agent, w, template)
+			return controllerutil.SetControllerReference(agent, w, r.Scheme)
+		})
+	}
+	if err != nil {
+		log.Error(err, "Failed to create or update workload")
+		msg := fmt.Sprintf("Failed to create or update %s: %v", kind, err)
+		setNotReady(agent, ReasonPodCreateFailed, msg)
+		setDegraded(agent, ReasonPodCreateFailed, msg)
+		return r.updateAgentStatus(ctx, agent, PhaseFailed, msg)
+	}
+	if op != controllerutil.OperationResultNone {
+		log.Info("Reconciled workload for Agent", "Workload.Name", workload.GetName(), "operation", op)
+	}
+
+	// The kubelet restarts the containers of workload pods, so the operator's
+	// restart backoff does not apply
+	agent.Status.NextRestartTime = nil
+	agent.Status.ConsecutiveRestarts = 0
+
+	pod, err := r.currentAgentPod(ctx, agent)
+	if err != nil {
+		log.Error(err, "Failed to list agent pods")
+		return ctrl.Result{}, err
+	}
+	if pod != nil {
+		setPodStatus(agent, pod)
+		if reason := podFailureReason(pod); reason != "" {
+			agent.Status.LastFailureReason = reason
+		}
+	} else {
+		agent.Status.PodName = ""
+		agent.Status.StartTime = nil
+		setCondition(agent, agentsv1alpha1.ConditionPodScheduled, metav1.ConditionFalse, ReasonPodCreating,
+			fmt.Sprintf("Waiting for the %s to create a pod", kind))
+		setNotReady(agent, ReasonPodCreating, fmt.Sprintf("Waiting for the %s to create a pod", kind))
+	}
+
+	phase, message := PhasePending, fmt.Sprintf("Agent %s is waiting for its pod to become ready", kind)
+	if workloadReady(workload) {
+		phase, message = PhaseRunning, fmt.Sprintf("Agent %s is running", kind)
+	}
+	switch reason, msg := workloadFailure(workload, pod); {
+	case reason != "":
+		setDegraded(agent, reason, msg)
+		message = msg
+	default:
+		setHealthy(agent)
+	}
+
+	agent.Status.Phase = phase
+	agent.Status.Message = message
+	if !equality.Semantic.DeepEqual(*observed, agent.Status) || agent.Status.ObservedGeneration != agent.Generation {
+		return r.updateAgentStatus(ctx, agent, phase, message)
+	}
+	return ctrl.Result{}, nil
+}
+
+// mutateDeployment sets the spec of the Deployment of an Agent. The pod
+// template is only replaced if its spec hash differs from the desired one, so
+// server-side defaults do not cause an update.
+func (r *AgentReconciler) mutateDeployment(agent *agentsv1alpha1.Agent, deployment *appsv1.Deployment, template corev1.PodTemplateSpec) {
+	deployment.Labels = agentLabels(agent)
+	deployment.Spec.Replicas = ptr.To[int32](1)
+	if deployment.CreationTimestamp.IsZero() {
+		// The selector cannot be changed once the Deployment exists
+		deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: agentLabels(agent)}
+	}
+
+	strategy, maxUnavailable := updateStrategy(agent)
+	if strategy == agentsv1alpha1.UpdateStrategyRecreate {
+		deployment.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+	} else {
+		unavailable, surge := intstr.FromInt32(maxUnavailable), intstr.FromInt32(1)
+		deployment.Spec.Strategy = appsv1.DeploymentStrategy{
+			Type:          appsv1.RollingUpdateDeploymentStrategyType,
+			RollingUpdate: &appsv1.RollingUpdateDeployment{MaxUnavailable: &unavailable, MaxSurge: &surge},
+		}
+	}
+
+	template = withSpecHash(template)
+	if specHash(&deployment.Spec.Template) != specHash(&template) {
+		deployment.Spec.Template = template
+	}
+}
+
+// mutateStatefulSet sets the spec of the StatefulSet of an Agent, adding a
+// claim for its persistent volume if it has storage. The volume claim
+// templates of a StatefulSet cannot be changed, so storage is only set up
+// when the StatefulSet is created.
+func (r *AgentReconciler) mutateStatefulSet(agent *agentsv1alpha1.Agent, statefulSet *appsv1.StatefulSet, template corev1.PodTemplateSpec) {
+	statefulSet.Labels = agentLabels(agent)
+	statefulSet.Spec.Replicas = ptr.To[int32](1)
+	if statefulSet.CreationTimestamp.IsZero() {
+		statefulSet.Spec.Selector = &metav1.LabelSelector{MatchLabels: agentLabels(agent)}
+		statefulSet.Spec.ServiceName = agentWorkloadName(agent)
+		if storage := agent.Spec.Storage; storage != nil {
+			statefulSet.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{{
+				ObjectMeta: metav1.ObjectMeta{Name: dataVolumeName, Labels: agentLabels(agent)},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					StorageClassName: storage.StorageClassName,
+					Resources: corev1.VolumeResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: storage.Size},
+					},
+				},
+			}}
+		}
+	}
+	if len(statefulSet.Spec.VolumeClaimTemplates) > 0 {
+		mountPath := defaultDataMountPath
+		if agent.Spec.Storage != nil && agent.Spec.Storage.MountPath != "" {
+			mountPath = agent.Spec.Storage.MountPath
+		}
+		container := &template.Spec.Containers[0]
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      dataVolumeName,
+			MountPath: mountPath,
+		})
+	}
+	template = withSpecHash(template)
+	if specHash(&statefulSet.Spec.Template) != specHash(&template) {
+		statefulSet.Spec.Template = template
+	}
+}
+
+// ensureHeadlessService creates the headless Service that gives the pod of
+// an Agent's StatefulSet its stable network identity
+func (r *AgentReconciler) ensureHeadlessService(ctx context.Context, agent *agentsv1alpha1.Agent) error {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: agentWorkloadName(agent), Namespace: agent.Namespace},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, service, func() error {
+		service.Labels = agentLabels(agent)
+		service.Spec.ClusterIP = corev1.ClusterIPNone
+		service.Spec.Selector = agentLabels(agent)
+		return controllerutil.SetControllerReference(agent, service, r.Scheme)
+	})
+	return err
+}
+
+// deleteStaleWorkloads deletes what an Agent ran as before its workload kind
+// was changed
+func (r *AgentReconciler) deleteStaleWorkloads(ctx context.Context, agent *agentsv1alpha1.Agent, kind agentsv1alpha1.WorkloadKind) error {
+	log := logf.FromContext(ctx)
+	if kind != agentsv1alpha1.WorkloadKindPod {
+		pods, err := r.listAgentPods(ctx, agent)
+		if err != nil {
+			return err
+		}
+		for i := range pods {
+			log.Info("Deleting Pod of previous workload kind", "name", pods[i].Name)
+			if err := r.Delete(ctx, &pods[i]); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+
+	stale := map[agentsv1alpha1.WorkloadKind][]client.Object{
+		agentsv1alpha1.WorkloadKindDeployment:  {&appsv1.Deployment{}},
+		agentsv1alpha1.WorkloadKindStatefulSet: {&appsv1.StatefulSet{}, &corev1.Service{}},
+		workloadKindJob:                        {&batchv1.Job{}},
+	}
+	delete(stale, kind)
+	for staleKind, objs := range stale {
+		for _, obj := range objs {
+			err := r.Get(ctx, types.NamespacedName{Name: agentWorkloadName(agent), Namespace: agent.Namespace}, obj)
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if !metav1.IsControlledBy(obj, agent) || obj.GetDeletionTimestamp() != nil {
+				continue
+			}
+			log.Info("Deleting workload of previous workload kind", "kind", staleKind, "name", obj.GetName())
+			if err := r.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// currentAgentPod returns the newest pod of an Agent's workload that is not
+// being deleted, or nil if there is none
+func (r *AgentReconciler) currentAgentPod(ctx context.Context, agent *agentsv1alpha1.Agent) (*corev1.Pod, error) {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(agent.Namespace), client.MatchingLabels(agentLabels(agent))); err != nil {
+		return nil, err
+	}
+	return newestPod(pods.Items), nil
+}
+
+// newestPod returns the newest of the given pods that is not being deleted,
+// or nil if there is none
+func newestPod(pods []corev1.Pod) *corev1.Pod {
+	var newest *corev1.Pod
+	for i := range pods {
+		pod := &pods[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if newest == nil || newest.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			newest = pod
+		}
+	}
+	return newest
+}
+
+// workloadReady reports whether the pod of a workload is up to date and ready
+func workloadReady(workload client.Object) bool {
+	switch w := workload.(type) {
+	case *appsv1.Deployment:
+		return w.Status.ObservedGeneration >= w.Generation && w.Status.UpdatedReplicas >= 1 && w.Status.AvailableReplicas >= 1
+	case *appsv1.StatefulSet:
+		return w.Status.ObservedGeneration >= w.Generation && w.Status.UpdatedReplicas >= 1 && w.Status.ReadyReplicas >= 1
+	}
+	return false
+}
+
+// workloadFailure explains why the workload of an Agent is failing, e.g.
+// its container is crash looping, or returns an empty reason if it is not
+func workloadFailure(workload client.Object, pod *corev1.Pod) (string, string) {
+	if d, ok := workload.(*appsv1.Deployment); ok {
+		for _, c := range d.Status.Conditions {
+			if c.Type == appsv1.DeploymentProgressing && c.Status == corev1.ConditionFalse && c.Reason == ReasonProgressDeadlineExceeded {
+				return ReasonProgressDeadlineExceeded, c.Message
+			}
+		}
+	}
+	if pod == nil {
+		return "", ""
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if w := cs.State.Waiting; w != nil && w.Reason != "" && w.Reason != "ContainerCreating" && w.Reason != "PodInitializing" {
+			return w.Reason, fmt.Sprintf("Container %s of pod %s is waiting: %s %s", cs.Name, pod.Name, w.Reason, w.Message)
+		}
+	}
+	return "", ""
+}
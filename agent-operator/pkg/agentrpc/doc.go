@@ -0,0 +1,6 @@
+// Package agentrpc contains the gRPC service of the agent-operator, generated
+// from agents.proto. Go services can dial the operator's gRPC port and use
+// NewAgentServiceClient to send messages to agents and follow their replies.
+package agentrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative agents.proto
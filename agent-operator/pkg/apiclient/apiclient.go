@@ -0,0 +1,15 @@
+// Package apiclient is a typed client for the agent-operator HTTP API,
+// generated from the OpenAPI document the operator serves at
+// /api/v1/openapi.json
+package apiclient
+
+import (
+	_ "embed"
+)
+
+//go:generate go run github.com/oapi-codegen/oapi-codegen/v2/cmd/oapi-codegen@v2.4.1 -config oapi-codegen.yaml openapi.json
+
+// OpenAPISpec is the OpenAPI 3 document describing the operator API
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
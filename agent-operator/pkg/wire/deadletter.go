@@ -0,0 +1,79 @@
+package wire
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Dead-letter entry fields, written next to the fields of the original
+// inbox entry
+const (
+	FieldAgent          = "agent"
+	FieldSource         = "source"
+	FieldSourceID       = "source_id"
+	FieldDeliveries     = "deliveries"
+	FieldDeadLetteredAt = "dead_lettered_at"
+)
+
+// DeadLetter is an inbox message that was moved to the dead-letter stream of
+// its agent type
+type DeadLetter struct {
+	// Agent is the name of the agent the message was sent to
+	Agent string `json:"agent"`
+
+	// Source is the inbox stream the message was read from
+	Source string `json:"source"`
+
+	// SourceID is the ID of the entry in the inbox stream
+	SourceID string `json:"sourceId"`
+
+	// Deliveries is how many times the message was delivered without being
+	// acknowledged
+	Deliveries int64 `json:"deliveries"`
+
+	// DeadLetteredAt is when the message was moved to the dead-letter stream
+	DeadLetteredAt time.Time `json:"deadLetteredAt"`
+
+	// Envelope is the original message
+	Envelope *Envelope `json:"envelope"`
+}
+
+// EncodeDeadLetter converts a dead letter into stream entry values
+func EncodeDeadLetter(dl *DeadLetter) (map[string]interface{}, error) {
+	values, err := Encode(dl.Envelope)
+	if err != nil {
+		return nil, err
+	}
+	values[FieldAgent] = dl.Agent
+	values[FieldSource] = dl.Source
+	values[FieldSourceID] = dl.SourceID
+	values[FieldDeliveries] = strconv.FormatInt(dl.Deliveries, 10)
+	values[FieldDeadLetteredAt] = dl.DeadLetteredAt.UTC().Format(time.RFC3339)
+	return values, nil
+}
+
+// DecodeDeadLetter converts stream entry values back into a dead letter
+func DecodeDeadLetter(values map[string]interface{}) (*DeadLetter, error) {
+	env, err := Decode(values)
+	if err != nil {
+		return nil, err
+	}
+	dl := &DeadLetter{
+		Agent:    stringValue(values, FieldAgent),
+		Source:   stringValue(values, FieldSource),
+		SourceID: stringValue(values, FieldSourceID),
+		Envelope: env,
+	}
+	if s := stringValue(values, FieldDeliveries); s != "" {
+		if dl.Deliveries, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid %q field: %w", FieldDeliveries, err)
+		}
+	}
+	if s := stringValue(values, FieldDeadLetteredAt); s != "" {
+		if dl.DeadLetteredAt, err = time.Parse(time.RFC3339, s); err != nil {
+			return nil, fmt.Errorf("invalid %q field: %w", FieldDeadLetteredAt, err)
+		}
+	}
+	return dl, nil
+}
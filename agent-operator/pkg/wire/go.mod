@@ -0,0 +1,3 @@
+module github.com/Algoluna/agent-operator/pkg/wire
+
+go 1.21
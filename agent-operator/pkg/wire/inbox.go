@@ -0,0 +1,135 @@
+package wire
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Inbox trimming policies
+const (
+	// TrimMaxLen keeps approximately the newest InboxLimits.MaxLength
+	// entries of an inbox
+	TrimMaxLen = "MaxLen"
+	// TrimMinID drops inbox entries older than InboxLimits.Retention
+	TrimMinID = "MinID"
+)
+
+// DefaultInboxRetention is how long inbox entries are kept with TrimMinID
+// if no retention is configured
+const DefaultInboxRetention = 24 * time.Hour
+
+// InboxLimits bound the size of an agent's inbox stream. Entries stay in a
+// stream after they have been consumed, so without limits an inbox only ever
+// grows. Everyone who adds to an inbox should trim it with MaxLen or MinID
+// and refuse to add to it once its backlog reaches BacklogLimit.
+type InboxLimits struct {
+	// MaxLength caps the inbox with TrimMaxLen, 0 for no cap
+	MaxLength int64
+	// TrimPolicy is TrimMaxLen (the default) or TrimMinID
+	TrimPolicy string
+	// Retention is the age at which TrimMinID drops entries, or
+	// DefaultInboxRetention if 0
+	Retention time.Duration
+	// MaxBacklog is how many unconsumed messages the inbox may hold, or
+	// MaxLength if 0
+	MaxBacklog int64
+}
+
+// MaxLen returns the approximate MAXLEN to trim the inbox to when adding to
+// it, or 0 if it is not trimmed by length
+func (l InboxLimits) MaxLen() int64 {
+	if l.TrimPolicy == TrimMinID {
+		return 0
+	}
+	return l.MaxLength
+}
+
+// MinID returns the MINID to trim the inbox to when adding to it at now, or
+// "" if it is not trimmed by age
+func (l InboxLimits) MinID(now time.Time) string {
+	if l.TrimPolicy != TrimMinID {
+		return ""
+	}
+	retention := l.Retention
+	if retention <= 0 {
+		retention = DefaultInboxRetention
+	}
+	return MinIDAt(now.Add(-retention))
+}
+
+// Trim returns how to trim the inbox when adding to it at now: either an
+// approximate MAXLEN or an approximate MINID, the other being zero
+func (l InboxLimits) Trim(now time.Time) (maxLen int64, minID string) {
+	if maxLen := l.MaxLen(); maxLen > 0 {
+		return maxLen, ""
+	}
+	return 0, l.MinID(now)
+}
+
+// BacklogLimit returns how many unconsumed messages the inbox may hold
+// before new messages are refused, or 0 for no limit. By default it is the
+// inbox length cap, so trimming never drops messages the agent has not seen.
+func (l InboxLimits) BacklogLimit() int64 {
+	if l.MaxBacklog > 0 {
+		return l.MaxBacklog
+	}
+	return l.MaxLength
+}
+
+// MinIDAt returns the smallest ID of a stream entry added at or after t
+func MinIDAt(t time.Time) string {
+	return fmt.Sprintf("%d-0", t.UnixMilli())
+}
+
+// InboxGroupState is what XINFO GROUPS reports about a consumer group of an
+// inbox stream. Valkey reports a lag it cannot determine, e.g. after entries
+// were deleted or trimmed, as nil, which clients read as 0.
+type InboxGroupState struct {
+	Name            string
+	Lag             int64
+	Pending         int64
+	LastDeliveredID string
+	EntriesRead     int64
+}
+
+// InboxStreamState is what XINFO STREAM reports about an inbox stream
+type InboxStreamState struct {
+	Length          int64
+	LastGeneratedID string
+	EntriesAdded    int64
+}
+
+// InboxBacklog returns how many messages in an inbox the agent has not yet
+// consumed, i.e. not yet read or read but not yet acknowledged, given the
+// consumer groups of the inbox and the inbox itself. Until the agent has
+// created InboxGroup it has seen nothing, so the whole inbox is backlog.
+func InboxBacklog(groups []InboxGroupState, stream InboxStreamState) int64 {
+	for _, group := range groups {
+		if group.Name == InboxGroup {
+			return groupLag(group, stream) + group.Pending
+		}
+	}
+	return stream.Length
+}
+
+// groupLag returns how many entries of the inbox have not been delivered to
+// a group yet. A lag of 0 is only taken as such if the group has been
+// delivered the last entry; otherwise it is estimated from the entries read
+// by the group, bounded by the entries the inbox still holds.
+func groupLag(group InboxGroupState, stream InboxStreamState) int64 {
+	if group.Lag != 0 || group.LastDeliveredID == stream.LastGeneratedID {
+		return group.Lag
+	}
+	undelivered := max(stream.Length-group.Pending, 0)
+	if lag := stream.EntriesAdded - group.EntriesRead; lag > 0 && lag < undelivered {
+		return lag
+	}
+	return undelivered
+}
+
+// IsMissingStream reports whether err is Valkey's answer to inspecting a
+// stream that does not exist
+func IsMissingStream(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "ERR no such key")
+}
@@ -0,0 +1,66 @@
+package wire
+
+import "fmt"
+
+// KeyPattern returns the Valkey ACL key pattern that covers every stream
+// belonging to agents of the given type
+func KeyPattern(agentType string) string {
+	return fmt.Sprintf("agent:%s:*", agentType)
+}
+
+// InboxKey returns the stream an agent reads its incoming messages from
+func InboxKey(agentType, agentName string) string {
+	return fmt.Sprintf("agent:%s:%s:inbox", agentType, agentName)
+}
+
+// InboxGroup is the consumer group agents read their inbox stream through.
+// Each agent pod is a consumer of the group, named after the pod.
+const InboxGroup = "agent"
+
+// ReplyKey returns the ephemeral stream an agent writes the reply to a
+// single request to. The correlation ID of the request names the stream.
+func ReplyKey(agentType, agentName, correlationID string) string {
+	return fmt.Sprintf("agent:%s:%s:reply:%s", agentType, agentName, correlationID)
+}
+
+// MessageStatusKey returns the key the operator tracks the delivery status
+// of a single request under
+func MessageStatusKey(agentType, agentName, messageID string) string {
+	return fmt.Sprintf("agent:%s:%s:message:%s", agentType, agentName, messageID)
+}
+
+// OutboxKey returns the capped stream every reply and partial reply of an
+// agent is also published to, for consumers that follow an agent's output
+func OutboxKey(agentType, agentName string) string {
+	return fmt.Sprintf("agent:%s:%s:outbox", agentType, agentName)
+}
+
+// DeadLetterKey returns the stream inbox messages of agents of the given
+// type are moved to once they have been delivered too many times without
+// being acknowledged
+func DeadLetterKey(agentType string) string {
+	return fmt.Sprintf("agent:%s:deadletter", agentType)
+}
+
+// LegacyInboxKey returns the inbox stream used before the wire format was
+// versioned. It is not covered by the per-type Valkey ACL.
+func LegacyInboxKey(agentName string) string {
+	return fmt.Sprintf("agent:%s:inbox", agentName)
+}
+
+// LegacyReplyKey returns the shared reply stream used before the wire
+// format was versioned
+func LegacyReplyKey(agentName string) string {
+	return fmt.Sprintf("agent:%s:reply", agentName)
+}
+
+// IdempotencyKey returns the key the operator remembers which message a
+// request with an Idempotency-Key header created under. digest identifies
+// the caller and the header value.
+func IdempotencyKey(agentType, agentName, digest string) string {
+	return fmt.Sprintf("agent:%s:%s:idempotency:%s", agentType, agentName, digest)
+}
+
+// PendingMessagesKey is the sorted set of the status keys of the messages
+// the operator has not seen a reply to yet, scored by their deadline
+const PendingMessagesKey = "operator:pending-messages"
@@ -0,0 +1,69 @@
+package wire
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Two layouts predate the versioned wire format:
+//
+//   - the operator API and agentctl wrote flat entries with "payload",
+//     "sender", "correlation_id" and "reply_to" fields
+//   - the Python SDK wrote a JSON object into "data" whose payload was
+//     itself a JSON encoded string if it replied with an object or array
+//
+// Neither carries a "v" field. decodeLegacy reads both.
+
+// decodeLegacy converts an unversioned stream entry into an envelope
+func decodeLegacy(values map[string]interface{}) (*Envelope, error) {
+	if data, ok := values[FieldData].(string); ok {
+		var env Envelope
+		if err := json.Unmarshal([]byte(data), &env); err != nil {
+			return nil, fmt.Errorf("failed to decode legacy envelope: %w", err)
+		}
+		env.Payload = unquotePayload(env.Payload)
+		return &env, nil
+	}
+
+	env := &Envelope{
+		Sender:        stringValue(values, "sender"),
+		ReplyTo:       stringValue(values, "reply_to"),
+		CorrelationID: stringValue(values, "correlation_id"),
+		Type:          stringValue(values, "type"),
+	}
+	if payload, ok := values["payload"].(string); ok {
+		if json.Valid([]byte(payload)) {
+			env.Payload = json.RawMessage(payload)
+		} else {
+			// Plain text payloads were written as-is
+			quoted, _ := json.Marshal(payload)
+			env.Payload = quoted
+		}
+	}
+	if env.Type == "" && env.Payload != nil {
+		env.Type = TypeRequest
+	}
+	return env, nil
+}
+
+// unquotePayload unwraps a payload that was JSON encoded twice, returning it
+// unchanged if it is not a string holding a JSON object or array. Strings
+// holding scalars, such as "42", were not necessarily encoded twice and are
+// kept as strings.
+func unquotePayload(payload json.RawMessage) json.RawMessage {
+	var s string
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return payload
+	}
+	inner := strings.TrimSpace(s)
+	if inner == "" || (inner[0] != '{' && inner[0] != '[') || !json.Valid([]byte(inner)) {
+		return payload
+	}
+	return json.RawMessage(inner)
+}
+
+func stringValue(values map[string]interface{}, key string) string {
+	s, _ := values[key].(string)
+	return s
+}
@@ -0,0 +1,102 @@
+// Package wire defines the Valkey stream wire format shared by the
+// agent-operator, agentctl and the Python agent SDK: how streams are named
+// and how messages are laid out inside stream entries.
+//
+// A version 1 stream entry carries two fields: "v" holding the wire format
+// version and "data" holding a JSON encoded Envelope.
+package wire
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Version is the wire format version written by Encode
+const Version = "1"
+
+// ContentTypeJSON is the content type of JSON payloads
+const ContentTypeJSON = "application/json"
+
+// Stream entry fields
+const (
+	FieldVersion = "v"
+	FieldData    = "data"
+)
+
+// Message types
+const (
+	// TypeRequest is a message sent to an agent's inbox
+	TypeRequest = "request"
+	// TypeReply is an agent's answer to a request
+	TypeReply = "reply"
+	// TypeChunk is a partial answer to a request (e.g. LLM tokens) that
+	// is followed by more chunks and eventually a TypeReply
+	TypeChunk = "chunk"
+	// TypeAck is written to the reply stream by an agent when it picks a
+	// request up, before it starts working on it
+	TypeAck = "ack"
+	// TypeOpen marks the creation of a reply stream and carries no payload
+	TypeOpen = "open"
+)
+
+// Envelope is a single message exchanged over a stream
+type Envelope struct {
+	// ID uniquely identifies the message
+	ID string `json:"id,omitempty"`
+
+	// Type is one of the Type* constants
+	Type string `json:"type,omitempty"`
+
+	// Sender identifies who sent the message (a user, a service or an agent)
+	Sender string `json:"sender,omitempty"`
+
+	// ReplyTo is the stream the receiver should write its reply to
+	ReplyTo string `json:"reply_to,omitempty"`
+
+	// CorrelationID ties a reply to the request it answers. Replies echo the
+	// correlation ID of the request.
+	CorrelationID string `json:"correlation_id,omitempty"`
+
+	// ContentType describes the payload, ContentTypeJSON if empty
+	ContentType string `json:"content_type,omitempty"`
+
+	// Payload is the message body
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Encode converts an envelope into stream entry values
+func Encode(env *Envelope) (map[string]interface{}, error) {
+	if env.ContentType == "" && len(env.Payload) > 0 {
+		env.ContentType = ContentTypeJSON
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode envelope: %w", err)
+	}
+	return map[string]interface{}{
+		FieldVersion: Version,
+		FieldData:    string(data),
+	}, nil
+}
+
+// Decode converts stream entry values back into an envelope. Entries in the
+// legacy layouts (see legacy.go) are accepted as well.
+func Decode(values map[string]interface{}) (*Envelope, error) {
+	version, _ := values[FieldVersion].(string)
+	switch version {
+	case Version:
+		data, ok := values[FieldData].(string)
+		if !ok {
+			return nil, fmt.Errorf("entry is missing the %q field", FieldData)
+		}
+		var env Envelope
+		if err := json.Unmarshal([]byte(data), &env); err != nil {
+			return nil, fmt.Errorf("failed to decode envelope: %w", err)
+		}
+		return &env, nil
+	case "":
+		return decodeLegacy(values)
+	default:
+		return nil, fmt.Errorf("unsupported wire format version %q", version)
+	}
+}
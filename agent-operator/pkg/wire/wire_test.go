@@ -0,0 +1,241 @@
+package wire
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	in := &Envelope{
+		ID:            "msg-1",
+		Type:          TypeRequest,
+		Sender:        "alice",
+		ReplyTo:       ReplyKey("hello-agent", "hello", "c-1"),
+		CorrelationID: "c-1",
+		Payload:       json.RawMessage(`{"text":"hi"}`),
+	}
+	values, err := Encode(in)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if values[FieldVersion] != Version {
+		t.Fatalf("expected version %q, got %v", Version, values[FieldVersion])
+	}
+
+	out, err := Decode(values)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if out.CorrelationID != "c-1" || out.ReplyTo != in.ReplyTo || out.ContentType != ContentTypeJSON {
+		t.Fatalf("unexpected envelope: %+v", out)
+	}
+	if string(out.Payload) != `{"text":"hi"}` {
+		t.Fatalf("unexpected payload: %s", out.Payload)
+	}
+}
+
+func TestDecodeLegacy(t *testing.T) {
+	tests := []struct {
+		name          string
+		values        map[string]interface{}
+		wantType      string
+		wantPayload   string
+		wantCorrelate string
+	}{
+		{
+			name: "flat operator entry",
+			values: map[string]interface{}{
+				"payload":        `{"text":"hi"}`,
+				"sender":         "alice",
+				"correlation_id": "c-1",
+				"reply_to":       "agent:hello:reply",
+			},
+			wantType:      TypeRequest,
+			wantPayload:   `{"text":"hi"}`,
+			wantCorrelate: "c-1",
+		},
+		{
+			name:        "flat entry with plain text payload",
+			values:      map[string]interface{}{"payload": "hello"},
+			wantType:    TypeRequest,
+			wantPayload: `"hello"`,
+		},
+		{
+			name: "sdk reply with double encoded payload",
+			values: map[string]interface{}{
+				"data": `{"id":"m-1","type":"reply","reply_to":"m-1","payload":"{\"response\": \"Hello!\"}"}`,
+			},
+			wantType:    TypeReply,
+			wantPayload: `{"response": "Hello!"}`,
+		},
+		{
+			name: "sdk reply with double encoded array",
+			values: map[string]interface{}{
+				"data": `{"id":"m-1","type":"reply","payload":"[1, 2]"}`,
+			},
+			wantType:    TypeReply,
+			wantPayload: `[1, 2]`,
+		},
+		{
+			name: "sdk entry with string payload",
+			values: map[string]interface{}{
+				"data": `{"id":"m-1","type":"reply","payload":"42"}`,
+			},
+			wantType:    TypeReply,
+			wantPayload: `"42"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := Decode(tt.values)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if env.Type != tt.wantType {
+				t.Errorf("type = %q, want %q", env.Type, tt.wantType)
+			}
+			if string(env.Payload) != tt.wantPayload {
+				t.Errorf("payload = %s, want %s", env.Payload, tt.wantPayload)
+			}
+			if env.CorrelationID != tt.wantCorrelate {
+				t.Errorf("correlation ID = %q, want %q", env.CorrelationID, tt.wantCorrelate)
+			}
+		})
+	}
+}
+
+func TestDecodeUnknownVersion(t *testing.T) {
+	if _, err := Decode(map[string]interface{}{FieldVersion: "99", FieldData: "{}"}); err == nil {
+		t.Fatal("expected an error for an unknown version")
+	}
+}
+
+func TestDeadLetterRoundTrip(t *testing.T) {
+	in := &DeadLetter{
+		Agent:          "hello",
+		Source:         InboxKey("hello-agent", "hello"),
+		SourceID:       "1700000000000-0",
+		Deliveries:     6,
+		DeadLetteredAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Envelope: &Envelope{
+			ID:      "msg-1",
+			Type:    TypeRequest,
+			Payload: json.RawMessage(`{"text":"hi"}`),
+		},
+	}
+	values, err := EncodeDeadLetter(in)
+	if err != nil {
+		t.Fatalf("EncodeDeadLetter: %v", err)
+	}
+
+	out, err := DecodeDeadLetter(values)
+	if err != nil {
+		t.Fatalf("DecodeDeadLetter: %v", err)
+	}
+	if out.Agent != in.Agent || out.Source != in.Source || out.SourceID != in.SourceID || out.Deliveries != 6 {
+		t.Fatalf("unexpected dead letter: %+v", out)
+	}
+	if !out.DeadLetteredAt.Equal(in.DeadLetteredAt) {
+		t.Fatalf("unexpected dead-lettered time: %v", out.DeadLetteredAt)
+	}
+	if out.Envelope.ID != "msg-1" || string(out.Envelope.Payload) != `{"text":"hi"}` {
+		t.Fatalf("unexpected envelope: %+v", out.Envelope)
+	}
+}
+
+func TestInboxLimits(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+
+	byLength := InboxLimits{MaxLength: 1000}
+	if byLength.MaxLen() != 1000 || byLength.MinID(now) != "" {
+		t.Fatalf("unexpected trimming for %+v: %d %q", byLength, byLength.MaxLen(), byLength.MinID(now))
+	}
+	if byLength.BacklogLimit() != 1000 {
+		t.Fatalf("expected backlog limit to default to the length cap, got %d", byLength.BacklogLimit())
+	}
+
+	byAge := InboxLimits{MaxLength: 1000, TrimPolicy: TrimMinID, Retention: time.Minute, MaxBacklog: 50}
+	if byAge.MaxLen() != 0 {
+		t.Fatalf("expected no MAXLEN with %s, got %d", TrimMinID, byAge.MaxLen())
+	}
+	if got := byAge.MinID(now); got != "1699999940000-0" {
+		t.Fatalf("unexpected MINID %q", got)
+	}
+	if byAge.BacklogLimit() != 50 {
+		t.Fatalf("unexpected backlog limit %d", byAge.BacklogLimit())
+	}
+
+	if got := (InboxLimits{TrimPolicy: TrimMinID}).MinID(now); got != MinIDAt(now.Add(-DefaultInboxRetention)) {
+		t.Fatalf("expected default retention, got MINID %q", got)
+	}
+	if (InboxLimits{}).BacklogLimit() != 0 {
+		t.Fatal("expected no backlog limit without limits")
+	}
+
+	if maxLen, minID := byLength.Trim(now); maxLen != 1000 || minID != "" {
+		t.Fatalf("unexpected trim for %+v: %d %q", byLength, maxLen, minID)
+	}
+	if maxLen, minID := byAge.Trim(now); maxLen != 0 || minID != "1699999940000-0" {
+		t.Fatalf("unexpected trim for %+v: %d %q", byAge, maxLen, minID)
+	}
+	if maxLen, minID := (InboxLimits{}).Trim(now); maxLen != 0 || minID != "" {
+		t.Fatalf("expected no trimming without limits, got %d %q", maxLen, minID)
+	}
+}
+
+func TestInboxBacklog(t *testing.T) {
+	stream := InboxStreamState{Length: 7, LastGeneratedID: "7-0", EntriesAdded: 7}
+	tests := []struct {
+		name   string
+		groups []InboxGroupState
+		stream InboxStreamState
+		want   int64
+	}{
+		{name: "no group", stream: stream, want: 7},
+		{name: "other group only", groups: []InboxGroupState{{Name: "audit", Lag: 1}}, stream: stream, want: 7},
+		{
+			name:   "inbox group",
+			groups: []InboxGroupState{{Name: "audit", Lag: 1}, {Name: InboxGroup, Lag: 3, Pending: 2, LastDeliveredID: "4-0", EntriesRead: 4}},
+			stream: stream,
+			want:   5,
+		},
+		{
+			name:   "caught up",
+			groups: []InboxGroupState{{Name: InboxGroup, Pending: 1, LastDeliveredID: "7-0", EntriesRead: 7}},
+			stream: stream,
+			want:   1,
+		},
+		{
+			// Trimming the inbox leaves the lag unknown, which reads as 0
+			name:   "unknown lag after trimming",
+			groups: []InboxGroupState{{Name: InboxGroup, Pending: 2, LastDeliveredID: "480-0", EntriesRead: 480}},
+			stream: InboxStreamState{Length: 60, LastGeneratedID: "500-0", EntriesAdded: 500},
+			want:   22,
+		},
+		{
+			name:   "unknown lag bounded by the inbox length",
+			groups: []InboxGroupState{{Name: InboxGroup, Pending: 2, LastDeliveredID: "10-0", EntriesRead: 10}},
+			stream: InboxStreamState{Length: 20, LastGeneratedID: "500-0", EntriesAdded: 500},
+			want:   20,
+		},
+		{
+			name:   "unknown lag without entries read",
+			groups: []InboxGroupState{{Name: InboxGroup, LastDeliveredID: "10-0", EntriesRead: 0}},
+			stream: InboxStreamState{Length: 20, LastGeneratedID: "30-0"},
+			want:   20,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InboxBacklog(tt.groups, tt.stream); got != tt.want {
+				t.Errorf("backlog = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if !IsMissingStream(errors.New("ERR no such key")) || IsMissingStream(errors.New("WRONGTYPE")) || IsMissingStream(nil) {
+		t.Fatal("unexpected IsMissingStream result")
+	}
+}
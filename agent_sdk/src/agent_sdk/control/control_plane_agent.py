@@ -73,6 +73,7 @@ class ControlPlaneAgent(ABC):
             try:
                 self.handle_message(message)
                 self.context.save_state(self.agent)
+                self.context.ack(message)
             except Exception as e:
                 self.logger.error(f"Error processing message: {e}", exc_info=True)
                 try:
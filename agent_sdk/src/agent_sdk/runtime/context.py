@@ -51,6 +51,10 @@ class RuntimeContext:
         # TODO: Block on Redis stream for incoming message
         return self._messaging.receive()
 
+    def ack(self, message: "IncomingMessage") -> None:
+        # Mark the message as handled so it is not redelivered
+        self._messaging.ack(message)
+
     def load_state(self, agent) -> None:
         # Hydrate agent.state from Postgres
         state = self._state.load_state(self.agent_id)
@@ -116,6 +116,7 @@ def main():
         msg = agent.ctx.receive()
         agent.on_message(msg)
         agent.ctx.save_state(agent)
+        agent.ctx.ack(msg)
 
 if __name__ == "__main__":
     main()
@@ -9,14 +9,48 @@ import redis
 import json
 import logging
 import time
+import uuid
 from agent_sdk.types import IncomingMessage
 
+# Wire format shared with the agent-operator and agentctl
+# (see agent-operator/pkg/wire)
+WIRE_VERSION = "1"
+FIELD_VERSION = "v"
+FIELD_DATA = "data"
+CONTENT_TYPE_JSON = "application/json"
+TYPE_REQUEST = "request"
+TYPE_REPLY = "reply"
+TYPE_CHUNK = "chunk"
+TYPE_ACK = "ack"
+TYPE_OPEN = "open"
+
+# Dead-letter entry fields, next to the fields of the original entry
+FIELD_AGENT = "agent"
+FIELD_SOURCE = "source"
+FIELD_SOURCE_ID = "source_id"
+FIELD_DELIVERIES = "deliveries"
+FIELD_DEAD_LETTERED_AT = "dead_lettered_at"
+
+# Approximate number of entries kept in an agent's outbox stream
+OUTBOX_MAXLEN = 1000
+
+# Consumer group agents read their inbox through
+INBOX_GROUP = "agent"
+# Idle time after which another consumer's unacknowledged entries are claimed
+DEFAULT_CLAIM_IDLE_MS = 300000
+# Deliveries after which an unacknowledged message is dead-lettered
+DEFAULT_MAX_DELIVERIES = 5
+# How long a read blocks waiting for new entries
+BLOCK_MS = 5000
+
 class Messaging:
     def __init__(self, redis_url: str, agent_type: str, agent_id: str):
         self.redis_url = redis_url
         self.agent_type = agent_type
         self.agent_id = agent_id
         self.stream_key = f"agent:{agent_type}:{agent_id}:inbox"
+        self.outbox_key = f"agent:{agent_type}:{agent_id}:outbox"
+        self.dead_letter_key = f"agent:{agent_type}:deadletter"
         self.logger = logging.getLogger("Messaging")
         # Use username from env or default to agent_helloagent
         import os
@@ -35,43 +69,228 @@ class Messaging:
             decode_responses=True,
             socket_connect_timeout=5
         )
-        self.last_id = "0-0"
+        # Inbox consumer group, see agent-operator/internal/controller/inbox.go
+        self.group = os.environ.get("AGENT_INBOX_GROUP", INBOX_GROUP)
+        self.consumer = os.environ.get("HOSTNAME") or agent_id
+        self.claim_idle_ms = int(os.environ.get("AGENT_INBOX_CLAIM_IDLE_MS", DEFAULT_CLAIM_IDLE_MS))
+        self.max_deliveries = int(os.environ.get("AGENT_INBOX_MAX_DELIVERIES", DEFAULT_MAX_DELIVERIES))
+        # Start by re-reading entries delivered to this consumer but never acknowledged
+        self.pending_id = "0"
 
     def receive(self):
-        # Block on Redis stream for incoming message
+        # Block on the inbox consumer group for the next incoming message.
+        # Entries stay pending until ack() is called, so a message whose
+        # handler crashed is redelivered: first to this consumer when it
+        # restarts, or to another consumer once it has been idle for longer
+        # than claim_idle_ms.
         while True:
             try:
-                streams = self.redis.xread({self.stream_key: self.last_id}, block=0, count=1)
-                if streams:
-                    _, messages = streams[0]
-                    for msg_id, fields in messages:
-                        self.last_id = msg_id
-                        try:
-                            data = json.loads(fields.get("data", "{}"))
-                            return IncomingMessage(
-                                id=data.get("id", msg_id),
-                                sender=data.get("sender", ""),
-                                reply_to=data.get("reply_to"),
-                                payload=data.get("payload", {}),
-                                type=data.get("type", "")
-                            )
-                        except Exception as e:
-                            self.logger.error(f"Error parsing message: {e}")
+                for msg_id, fields in self._next_entries():
+                    if self._dead_letter_if_exhausted(msg_id, fields):
+                        continue
+                    message = self._parse_entry(msg_id, fields)
+                    if message is not None:
+                        self._notify_delivered(message)
+                        return message
+            except redis.exceptions.ResponseError as e:
+                if "NOGROUP" in str(e):
+                    self._ensure_group()
+                    continue
+                self.logger.error(f"Error reading from Redis stream: {e}")
+                time.sleep(1)
             except Exception as e:
                 self.logger.error(f"Error reading from Redis stream: {e}")
                 time.sleep(1)
 
+    def ack(self, message):
+        # Acknowledge a message once it has been handled, removing it from
+        # the consumer group's pending entries list
+        if not message.stream_id:
+            return
+        try:
+            self.redis.xack(self.stream_key, self.group, message.stream_id)
+        except Exception as e:
+            self.logger.error(f"Error acknowledging message {message.stream_id}: {e}")
+
+    def _next_entries(self):
+        # Our own pending entries come first; they were delivered before a
+        # restart but never acknowledged
+        if self.pending_id is not None:
+            entries = self._read_group(self.pending_id)
+            if entries:
+                self.pending_id = entries[-1][0]
+                return entries
+            self.pending_id = None
+
+        # Then entries abandoned by other consumers
+        _, claimed, *_ = self.redis.xautoclaim(
+            self.stream_key, self.group, self.consumer,
+            min_idle_time=self.claim_idle_ms, start_id="0-0", count=1,
+        )
+        if claimed:
+            return [entry for entry in claimed if entry[1]]
+
+        # Then new entries
+        return self._read_group(">", block=BLOCK_MS)
+
+    def _read_group(self, last_id, block=None):
+        streams = self.redis.xreadgroup(
+            self.group, self.consumer, {self.stream_key: last_id}, count=1, block=block,
+        )
+        if not streams:
+            return []
+        _, entries = streams[0]
+        return entries
+
+    def _ensure_group(self):
+        try:
+            self.redis.xgroup_create(self.stream_key, self.group, id="0", mkstream=True)
+        except redis.exceptions.ResponseError as e:
+            if "BUSYGROUP" not in str(e):
+                raise
+
+    def _dead_letter_if_exhausted(self, msg_id, fields):
+        # Move a message that keeps failing (e.g. because it crashes the
+        # agent) to the dead-letter stream of the agent type instead of
+        # delivering it again
+        pending = self.redis.xpending_range(self.stream_key, self.group, min=msg_id, max=msg_id, count=1)
+        if not pending:
+            return False
+        deliveries = pending[0]["times_delivered"]
+        if deliveries <= self.max_deliveries:
+            return False
+
+        self.logger.warning(f"Moving message {msg_id} to {self.dead_letter_key} after {deliveries} deliveries")
+        try:
+            entry = encode_entry(decode_entry(fields))
+        except Exception:
+            entry = dict(fields)
+        entry.update({
+            FIELD_AGENT: self.agent_id,
+            FIELD_SOURCE: self.stream_key,
+            FIELD_SOURCE_ID: msg_id,
+            FIELD_DELIVERIES: str(deliveries),
+            FIELD_DEAD_LETTERED_AT: time.strftime("%Y-%m-%dT%H:%M:%SZ", time.gmtime()),
+        })
+        pipe = self.redis.pipeline(transaction=True)
+        pipe.xadd(self.dead_letter_key, entry)
+        pipe.xack(self.stream_key, self.group, msg_id)
+        pipe.execute()
+        return True
+
+    def _parse_entry(self, msg_id, fields):
+        try:
+            data = decode_entry(fields)
+        except Exception as e:
+            self.logger.error(f"Error parsing message {msg_id}: {e}")
+            self.redis.xack(self.stream_key, self.group, msg_id)
+            return None
+        if data.get("type") == TYPE_OPEN:
+            self.redis.xack(self.stream_key, self.group, msg_id)
+            return None
+        return IncomingMessage(
+            id=data.get("id") or msg_id,
+            sender=data.get("sender", ""),
+            reply_to=data.get("reply_to"),
+            payload=data.get("payload", {}),
+            type=data.get("type", ""),
+            correlation_id=data.get("correlation_id"),
+            stream_id=msg_id,
+        )
+
     def reply(self, message, payload):
         # Send reply to Redis stream (reply_to or sender's inbox)
+        self._send(message, payload, TYPE_REPLY)
+
+    def send_chunk(self, message, payload):
+        # Send a partial reply (e.g. streamed LLM tokens) ahead of the final reply
+        self._send(message, payload, TYPE_CHUNK)
+
+    def _notify_delivered(self, message):
+        # Let the sender know the message has been picked up
+        if not message.reply_to:
+            return
+        try:
+            ack = {
+                "id": str(uuid.uuid4()),
+                "type": TYPE_ACK,
+                "sender": self.agent_id,
+                "correlation_id": message.correlation_id or message.id,
+            }
+            self.redis.xadd(message.reply_to, encode_entry(ack))
+        except Exception as e:
+            self.logger.error(f"Error acknowledging message: {e}")
+
+    def _send(self, message, payload, msg_type):
         target_stream = message.reply_to or f"agent:{message.sender}:inbox"
         try:
             msg = {
-                "id": message.id,
+                "id": str(uuid.uuid4()),
+                "type": msg_type,
                 "sender": self.agent_id,
-                "reply_to": message.id,
-                "payload": json.dumps(payload),
-                "type": "reply"
+                "correlation_id": message.correlation_id or message.id,
+                "content_type": CONTENT_TYPE_JSON,
+                "payload": payload,
             }
-            self.redis.xadd(target_stream, {"data": json.dumps(msg)})
+            entry = encode_entry(msg)
+            self.redis.xadd(target_stream, entry)
+            # Publish to the outbox as well, for clients following the agent's output
+            self.redis.xadd(self.outbox_key, entry, maxlen=OUTBOX_MAXLEN, approximate=True)
         except Exception as e:
-            self.logger.error(f"Error sending reply: {e}")
+            self.logger.error(f"Error sending {msg_type}: {e}")
+
+
+def encode_entry(envelope: dict) -> dict:
+    """Encode an envelope as the fields of a version 1 stream entry."""
+    return {FIELD_VERSION: WIRE_VERSION, FIELD_DATA: json.dumps(envelope)}
+
+
+def decode_entry(fields: dict) -> dict:
+    """
+    Decode the fields of a stream entry into an envelope dict.
+    Understands the versioned wire format as well as the legacy layouts:
+    flat entries with a "payload" field, and unversioned "data" envelopes
+    whose payload is a JSON encoded string.
+    """
+    version = fields.get(FIELD_VERSION)
+    if version == WIRE_VERSION:
+        return json.loads(fields[FIELD_DATA])
+    if version:
+        raise ValueError(f"unsupported wire format version {version!r}")
+
+    if FIELD_DATA in fields:
+        data = json.loads(fields[FIELD_DATA])
+        data["payload"] = _unquote_payload(data.get("payload"))
+        return data
+
+    data = {key: fields.get(key) for key in ("sender", "reply_to", "correlation_id", "type") if fields.get(key)}
+    data.setdefault("type", TYPE_REQUEST)
+    data["payload"] = _decode_flat_payload(fields.get("payload"))
+    return data
+
+
+def _decode_flat_payload(payload):
+    """Flat entries hold the payload as JSON, or as plain text."""
+    if isinstance(payload, str):
+        try:
+            return json.loads(payload)
+        except ValueError:
+            return payload
+    return payload
+
+
+def _unquote_payload(payload):
+    """
+    Unwrap a payload that was JSON encoded twice. Only strings holding a JSON
+    object or array are unwrapped; strings holding scalars, such as "42",
+    were not necessarily encoded twice and are kept as strings.
+    """
+    if not isinstance(payload, str):
+        return payload
+    inner = payload.strip()
+    if not inner.startswith(("{", "[")):
+        return payload
+    try:
+        return json.loads(inner)
+    except ValueError:
+        return payload
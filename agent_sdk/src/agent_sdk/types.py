@@ -7,12 +7,16 @@ Shared types for agent SDK.
 from typing import Any, Optional
 
 class IncomingMessage:
-    def __init__(self, id: str, sender: str, reply_to: Optional[str], payload: dict, type: str):
+    def __init__(self, id: str, sender: str, reply_to: Optional[str], payload: dict, type: str,
+                 correlation_id: Optional[str] = None, stream_id: Optional[str] = None):
         self.id = id
         self.sender = sender
         self.reply_to = reply_to
         self.payload = payload
         self.type = type
+        self.correlation_id = correlation_id
+        # ID of the inbox stream entry, used to acknowledge the message
+        self.stream_id = stream_id
 
     def __repr__(self):
         return f"IncomingMessage(id={self.id}, sender={self.sender}, type={self.type})"
@@ -0,0 +1,32 @@
+import json
+import unittest
+
+from agent_sdk.runtime.messaging import decode_entry
+
+
+class DecodeEntryTest(unittest.TestCase):
+    def decode_legacy_data(self, payload):
+        return decode_entry({"data": json.dumps({"type": "reply", "payload": payload})})["payload"]
+
+    def test_double_encoded_object(self):
+        self.assertEqual(self.decode_legacy_data(json.dumps({"text": "hi"})), {"text": "hi"})
+
+    def test_double_encoded_array(self):
+        self.assertEqual(self.decode_legacy_data(json.dumps([1, 2])), [1, 2])
+
+    def test_scalar_string_stays_a_string(self):
+        for payload in ("42", "true", "null", '"quoted"', "hello"):
+            with self.subTest(payload=payload):
+                self.assertEqual(self.decode_legacy_data(payload), payload)
+
+    def test_flat_payload(self):
+        self.assertEqual(decode_entry({"payload": '{"text": "hi"}'})["payload"], {"text": "hi"})
+        self.assertEqual(decode_entry({"payload": "hello"})["payload"], "hello")
+
+    def test_versioned_entry_is_not_unquoted(self):
+        fields = {"v": "1", "data": json.dumps({"type": "reply", "payload": "[1]"})}
+        self.assertEqual(decode_entry(fields)["payload"], "[1]")
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Algoluna/agent-operator/pkg/apiclient"
+)
+
+var (
+	broadcastPayload     string
+	broadcastSelector    string
+	broadcastQuorum      int
+	broadcastTimeout     int
+	broadcastOperatorURL string
+)
+
+// broadcastResponseGrace is how much longer than the broadcast timeout the
+// HTTP request may take, so the aggregated result is not cut off
+const broadcastResponseGrace = 10
+
+var broadcastCmd = &cobra.Command{
+	Use:   "broadcast <agent-type>",
+	Short: "Send a message to every agent of a type and collect the replies",
+	Long: `Send the same message to every agent of a type, or to those matching a
+label selector, through the agent-operator API. The command waits until the
+quorum of agents has replied or the timeout passes and prints each agent's
+outcome.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		agentType := args[0]
+		if broadcastPayload == "" {
+			return fmt.Errorf("--payload is required")
+		}
+		if !json.Valid([]byte(broadcastPayload)) {
+			return fmt.Errorf("--payload must be valid JSON")
+		}
+
+		client, url, err := newOperatorClient(broadcastOperatorURL, broadcastTimeout+broadcastResponseGrace)
+		if err != nil {
+			return err
+		}
+
+		req := apiclient.BroadcastRequest{
+			Payload: json.RawMessage(broadcastPayload),
+			Timeout: &broadcastTimeout,
+		}
+		if broadcastSelector != "" {
+			req.Selector = &broadcastSelector
+		}
+		if broadcastQuorum > 0 {
+			req.Quorum = &broadcastQuorum
+		}
+
+		fmt.Fprintf(os.Stderr, "Broadcasting to agents of type %s via API (%s)\n", agentType, url)
+		resp, err := client.BroadcastMessageWithResponse(context.Background(), agentType, req)
+		if err != nil {
+			return fmt.Errorf("failed to send HTTP request: %v", err)
+		}
+		result := resp.JSON200
+		if resp.StatusCode() == http.StatusGatewayTimeout {
+			result = resp.JSON504
+		}
+		if result == nil {
+			return fmt.Errorf("API request failed: %s - %s", resp.Status(), string(resp.Body))
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "AGENT\tSTATUS\tREPLY")
+		for _, r := range result.Results {
+			reply := ""
+			switch {
+			case r.Error != nil:
+				reply = "error: " + *r.Error
+			case r.Reply != nil && r.Reply.Payload != nil:
+				reply = string(*r.Reply.Payload)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", r.Agent, r.Status, reply)
+		}
+		w.Flush()
+
+		fmt.Printf("\n%d of %d agent(s) replied, quorum %d\n", result.Replied, result.Agents, result.Quorum)
+		if !result.QuorumReached {
+			return fmt.Errorf("quorum not reached within %ds", broadcastTimeout)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(broadcastCmd)
+	broadcastCmd.Flags().StringVar(&broadcastPayload, "payload", "", "Message payload (required)")
+	broadcastCmd.Flags().StringVarP(&broadcastSelector, "selector", "l", "", "Only send to agents matching this label selector")
+	broadcastCmd.Flags().IntVar(&broadcastQuorum, "quorum", 0, "Number of replies to wait for (default: all matching agents)")
+	broadcastCmd.Flags().IntVar(&broadcastTimeout, "timeout", 30, "Timeout in seconds to wait for replies")
+	broadcastCmd.Flags().StringVar(&broadcastOperatorURL, "operator-url", "", "Agent operator URL (default: auto-discover from current context)")
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Algoluna/agent-operator/pkg/wire"
+)
+
+var (
+	dlqOperatorURL string
+	dlqAgentName   string
+	dlqLimit       int
+)
+
+// deadLetter is a dead letter as returned by the operator API
+type deadLetter struct {
+	ID string `json:"id"`
+	wire.DeadLetter
+	Error string `json:"error,omitempty"`
+}
+
+var dlqCmd = &cobra.Command{
+	Use:   "dlq",
+	Short: "Inspect and manage dead-lettered messages",
+	Long: `Inspect and manage the dead-letter stream of an agent type.
+Inbox messages that are delivered too many times without being acknowledged,
+e.g. because they crash the agent, are moved there.`,
+}
+
+var dlqListCmd = &cobra.Command{
+	Use:   "list <agent-type>",
+	Short: "List dead letters of an agent type",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query := url.Values{}
+		query.Set("limit", fmt.Sprint(dlqLimit))
+		if dlqAgentName != "" {
+			query.Set("agent", dlqAgentName)
+		}
+
+		var response struct {
+			Length      int64        `json:"length"`
+			DeadLetters []deadLetter `json:"deadLetters"`
+		}
+		if err := callDeadLetterAPI(http.MethodGet, args[0], "?"+query.Encode(), &response); err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tAGENT\tDELIVERIES\tDEAD-LETTERED\tSENDER")
+		for _, dl := range response.DeadLetters {
+			if dl.Error != "" {
+				fmt.Fprintf(w, "%s\t\t\t\t(undecodable: %s)\n", dl.ID, dl.Error)
+				continue
+			}
+			sender := ""
+			if dl.Envelope != nil {
+				sender = dl.Envelope.Sender
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n",
+				dl.ID,
+				dl.Agent,
+				dl.Deliveries,
+				dl.DeadLetteredAt.Local().Format("2006-01-02 15:04:05"),
+				sender)
+		}
+		w.Flush()
+
+		fmt.Printf("\n%d dead letter(s) shown, %d in total\n", len(response.DeadLetters), response.Length)
+		return nil
+	},
+}
+
+var dlqShowCmd = &cobra.Command{
+	Use:   "show <agent-type> <id>",
+	Short: "Show a dead letter",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var dl deadLetter
+		if err := callDeadLetterAPI(http.MethodGet, args[0], "/"+args[1], &dl); err != nil {
+			return err
+		}
+		out, err := json.MarshalIndent(dl, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+var dlqReplayCmd = &cobra.Command{
+	Use:   "replay <agent-type> <id>",
+	Short: "Send a dead letter back to the inbox it came from",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var response struct {
+			Inbox   string `json:"inbox"`
+			InboxID string `json:"inboxId"`
+		}
+		if err := callDeadLetterAPI(http.MethodPost, args[0], "/"+args[1]+"/replay", &response); err != nil {
+			return err
+		}
+		fmt.Printf("Replayed %s to %s (ID: %s)\n", args[1], response.Inbox, response.InboxID)
+		return nil
+	},
+}
+
+var dlqPurgeCmd = &cobra.Command{
+	Use:   "purge <agent-type> [id]",
+	Short: "Delete one or all dead letters of an agent type",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 2 {
+			if err := callDeadLetterAPI(http.MethodDelete, args[0], "/"+args[1], nil); err != nil {
+				return err
+			}
+			fmt.Printf("Deleted dead letter %s\n", args[1])
+			return nil
+		}
+
+		var response struct {
+			Purged int64 `json:"purged"`
+		}
+		if err := callDeadLetterAPI(http.MethodDelete, args[0], "", &response); err != nil {
+			return err
+		}
+		fmt.Printf("Purged %d dead letter(s) of type %s\n", response.Purged, args[0])
+		return nil
+	},
+}
+
+// callDeadLetterAPI calls the dead-letter endpoint of the agent-operator API
+// for an agent type and decodes the JSON response into out, if not nil
+func callDeadLetterAPI(method, agentType, suffix string, out interface{}) error {
+	baseURL := dlqOperatorURL
+	if baseURL == "" {
+		discoveredURL, err := getOperatorURLFromKubeconfig()
+		if err != nil {
+			return fmt.Errorf("failed to get operator URL: %v", err)
+		}
+		baseURL = discoveredURL
+	}
+	endpoint := fmt.Sprintf("%s/api/v1/types/%s/deadletters%s", baseURL, url.PathEscape(agentType), suffix)
+
+	req, err := http.NewRequest(method, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+
+	// Set Kubernetes authentication if available
+	if err := setKubernetesAuth(req); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to set Kubernetes authentication: %v\n", err)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API request failed: %s - %s", resp.Status, string(body))
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(dlqCmd)
+	dlqCmd.AddCommand(dlqListCmd, dlqShowCmd, dlqReplayCmd, dlqPurgeCmd)
+	dlqCmd.PersistentFlags().StringVar(&dlqOperatorURL, "operator-url", "", "Agent operator URL (default: auto-discover from current context)")
+	dlqListCmd.Flags().StringVar(&dlqAgentName, "agent", "", "Only list dead letters of this agent")
+	dlqListCmd.Flags().IntVar(&dlqLimit, "limit", 50, "Maximum number of dead letters to list")
+}
@@ -1,20 +1,21 @@
 package cmd
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 
+	"github.com/Algoluna/agent-operator/pkg/apiclient"
+	"github.com/Algoluna/agent-operator/pkg/wire"
 	"github.com/Algoluna/agentctl/pkg/utils"
 )
 
@@ -27,6 +28,9 @@ var (
 	messageOperatorURL string
 )
 
+// replyStreamGracePeriod is how long a reply stream outlives the request timeout
+const replyStreamGracePeriod = time.Minute
+
 var messageCmd = &cobra.Command{
 	Use:   "message <agent-name>",
 	Short: "Send a message to a running agent and receive a reply",
@@ -45,6 +49,12 @@ var messageCmd = &cobra.Command{
 		if messagePayload == "" {
 			return fmt.Errorf("--payload is required")
 		}
+		if !json.Valid([]byte(messagePayload)) {
+			// Plain text payloads are sent as a JSON string, as before the
+			// wire format was versioned
+			quoted, _ := json.Marshal(messagePayload)
+			messagePayload = string(quoted)
+		}
 		if messageTimeout == 0 {
 			messageTimeout = 30
 		}
@@ -63,7 +73,7 @@ var messageCmd = &cobra.Command{
 
 		// Use the API method if enabled
 		if messageUseAPI {
-			return sendMessageViaAPI(agentName, messagePayload, messageTimeout, messageOperatorURL)
+			return sendMessageViaAPI(agentName, agentType, messagePayload, messageTimeout, messageOperatorURL)
 		}
 
 		// Default direct Redis/Valkey method
@@ -71,12 +81,18 @@ var messageCmd = &cobra.Command{
 			messageRedisURL = "redis://localhost:6379"
 		}
 
-		return sendMessageViaRedis(agentName, messagePayload, messageRedisURL, messageTimeout)
+		// Respect the agent's inbox limits like the operator API does
+		limits, err := utils.GetInboxLimits(agentName, agentType, kubeconfig)
+		if err != nil {
+			return fmt.Errorf("error reading inbox limits: %v", err)
+		}
+
+		return sendMessageViaRedis(agentName, agentType, messagePayload, messageRedisURL, messageTimeout, limits)
 	},
 }
 
 // sendMessageViaRedis sends a message directly to the agent via Redis/Valkey
-func sendMessageViaRedis(agentName, payload, redisURL string, timeout int) error {
+func sendMessageViaRedis(agentName, agentType, payload, redisURL string, timeout int, limits wire.InboxLimits) error {
 	ctx := context.Background()
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
@@ -84,16 +100,63 @@ func sendMessageViaRedis(agentName, payload, redisURL string, timeout int) error
 	}
 	rdb := redis.NewClient(opt)
 
+	// Each request gets its own reply stream, named after its correlation ID
+	correlationID := uuid.NewString()
+
 	// Compose stream and reply keys
-	streamKey := fmt.Sprintf("agent:%s:inbox", agentName)
-	replyKey := fmt.Sprintf("agent:%s:reply", agentName)
+	streamKey := wire.InboxKey(agentType, agentName)
+	replyKey := wire.ReplyKey(agentType, agentName, correlationID)
+
+	marker, err := wire.Encode(&wire.Envelope{Type: wire.TypeOpen})
+	if err != nil {
+		return err
+	}
+	values, err := wire.Encode(&wire.Envelope{
+		ID:            correlationID,
+		Type:          wire.TypeRequest,
+		Sender:        "agentctl",
+		ReplyTo:       replyKey,
+		CorrelationID: correlationID,
+		Payload:       json.RawMessage(payload),
+	})
+	if err != nil {
+		return fmt.Errorf("invalid payload: %v", err)
+	}
+
+	// Refuse to add to an inbox the agent is not keeping up with
+	if limit := limits.BacklogLimit(); limit > 0 {
+		backlog, err := inboxBacklog(ctx, rdb, streamKey)
+		if err != nil {
+			return fmt.Errorf("failed to read inbox backlog: %v", err)
+		}
+		if backlog >= limit {
+			return fmt.Errorf("inbox of agent %s is full: %d of %d messages not yet consumed", agentName, backlog, limit)
+		}
+	}
 
-	// Send message to agent's inbox stream
+	// Create the reply stream with a TTL so it is cleaned up even if no reply arrives
+	var markerID *redis.StringCmd
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		markerID = pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: replyKey,
+			Values: marker,
+		})
+		pipe.Expire(ctx, replyKey, time.Duration(timeout)*time.Second+replyStreamGracePeriod)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create reply stream: %v", err)
+	}
+	cursor := markerID.Val()
+
+	// Send message to agent's inbox stream, trimming it to the agent's limits
+	maxLen, minID := limits.Trim(time.Now())
 	msgID, err := rdb.XAdd(ctx, &redis.XAddArgs{
 		Stream: streamKey,
-		Values: map[string]interface{}{
-			"payload": payload,
-		},
+		Values: values,
+		MaxLen: maxLen,
+		MinID:  minID,
+		Approx: maxLen > 0 || minID != "",
 	}).Result()
 	if err != nil {
 		return fmt.Errorf("failed to send message: %v", err)
@@ -108,20 +171,28 @@ func sendMessageViaRedis(agentName, payload, redisURL string, timeout int) error
 		if now.After(deadline) {
 			return fmt.Errorf("timeout waiting for reply")
 		}
-		// Read latest message from reply stream
+		// Read replies written after the last entry we looked at
 		res, err := rdb.XRead(ctx, &redis.XReadArgs{
-			Streams: []string{replyKey, "0"},
-			Count:   1,
-			Block:   1000, // 1s
+			Streams: []string{replyKey, cursor},
+			Count:   10,
+			Block:   time.Second,
 		}).Result()
 		if err != nil && err != redis.Nil {
 			return fmt.Errorf("error reading reply: %v", err)
 		}
 		if len(res) > 0 && len(res[0].Messages) > 0 {
 			for _, msg := range res[0].Messages {
-				fmt.Printf("Reply: %v\n", msg.Values)
+				cursor = msg.ID
+				reply, err := wire.Decode(msg.Values)
+				if err != nil || reply.Type == wire.TypeOpen || reply.CorrelationID != correlationID {
+					continue
+				}
+				fmt.Printf("Reply: %s\n", reply.Payload)
+				// The reply has been delivered, drop the stream
+				rdb.Del(ctx, replyKey)
 				return nil
 			}
+			continue
 		}
 		// Sleep briefly before next poll
 		time.Sleep(500 * time.Millisecond)
@@ -129,7 +200,45 @@ func sendMessageViaRedis(agentName, payload, redisURL string, timeout int) error
 }
 
 // sendMessageViaAPI sends a message to an agent using the agent-operator API
-func sendMessageViaAPI(agentName, payload string, timeout int, operatorURL string) error {
+func sendMessageViaAPI(agentName, agentType, payload string, timeout int, operatorURL string) error {
+	client, url, err := newOperatorClient(operatorURL, timeout)
+	if err != nil {
+		return err
+	}
+
+	// Send the message and wait for the reply
+	fmt.Fprintf(os.Stderr, "Sending message to agent %s via API (%s)\n", agentName, url)
+	resp, err := client.SendMessageWithResponse(context.Background(), agentType, agentName, nil, apiclient.SendMessageRequest{
+		Payload: json.RawMessage(payload),
+		Timeout: &timeout,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send HTTP request: %v", err)
+	}
+	if resp.StatusCode() == http.StatusTooManyRequests {
+		return fmt.Errorf("rate limited by the agent-operator, retry in %s seconds", resp.HTTPResponse.Header.Get("Retry-After"))
+	}
+	if resp.StatusCode() == http.StatusServiceUnavailable {
+		return fmt.Errorf("agent inbox is full, retry in %s seconds: %s", resp.HTTPResponse.Header.Get("Retry-After"), string(resp.Body))
+	}
+	if resp.JSON200 == nil {
+		return fmt.Errorf("API request failed: %s - %s", resp.Status(), string(resp.Body))
+	}
+
+	// Print the reply
+	reply := resp.JSON200.Reply
+	if reply.Payload != nil {
+		fmt.Printf("Reply: %s\n", *reply.Payload)
+	} else {
+		fmt.Printf("Reply: %s\n", resp.Body)
+	}
+	return nil
+}
+
+// newOperatorClient creates a client of the agent-operator API whose
+// requests time out after timeout seconds. It returns the client together
+// with the URL it talks to, which is discovered if operatorURL is empty.
+func newOperatorClient(operatorURL string, timeout int) (*apiclient.ClientWithResponses, string, error) {
 	// Determine operator URL
 	url := operatorURL
 	if url == "" {
@@ -145,60 +254,20 @@ func sendMessageViaAPI(agentName, payload string, timeout int, operatorURL strin
 		}
 	}
 
-	// Construct the API endpoint URL
-	endpoint := fmt.Sprintf("%s/api/v1/agents/%s/messages", url, agentName)
-
-	// Prepare the request payload
-	reqPayload := map[string]interface{}{
-		"payload": json.RawMessage(payload),
-		"timeout": timeout,
-	}
-	reqData, err := json.Marshal(reqPayload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request payload: %v", err)
-	}
-
-	// Create the HTTP request
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(reqData))
-	if err != nil {
-		return fmt.Errorf("failed to create HTTP request: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	// Set Kubernetes authentication if available
-	if err := setKubernetesAuth(req); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Failed to set Kubernetes authentication: %v\n", err)
-	}
-
-	// Send the request
-	fmt.Fprintf(os.Stderr, "Sending message to agent %s via API (%s)\n", agentName, endpoint)
-	client := &http.Client{
-		Timeout: time.Duration(timeout) * time.Second,
-	}
-	resp, err := client.Do(req)
+	client, err := apiclient.NewClientWithResponses(url,
+		apiclient.WithHTTPClient(&http.Client{Timeout: time.Duration(timeout) * time.Second}),
+		apiclient.WithRequestEditorFn(func(ctx context.Context, req *http.Request) error {
+			// Set Kubernetes authentication if available
+			if err := setKubernetesAuth(req); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Failed to set Kubernetes authentication: %v\n", err)
+			}
+			return nil
+		}),
+	)
 	if err != nil {
-		return fmt.Errorf("failed to send HTTP request: %v", err)
+		return nil, "", fmt.Errorf("failed to create API client: %v", err)
 	}
-	defer resp.Body.Close()
-
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API request failed: %s - %s", resp.Status, string(body))
-	}
-
-	// Parse the response
-	var response struct {
-		Reply map[string]interface{} `json:"reply"`
-		ID    string                 `json:"id"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return fmt.Errorf("failed to decode response: %v", err)
-	}
-
-	// Print the reply
-	fmt.Printf("Reply: %v\n", response.Reply)
-	return nil
+	return client, url, nil
 }
 
 // getOperatorURLFromKubeconfig tries to determine the agent-operator URL from the current Kubernetes context
@@ -235,9 +304,40 @@ func setKubernetesAuth(req *http.Request) error {
 func init() {
 	rootCmd.AddCommand(messageCmd)
 	messageCmd.Flags().StringVar(&messageAgentName, "agent-name", "", "Name of the agent (optional if provided as argument)")
-	messageCmd.Flags().StringVar(&messagePayload, "payload", "", "Message payload (required)")
+	messageCmd.Flags().StringVar(&messagePayload, "payload", "", "Message payload, JSON or plain text sent as a JSON string (required)")
 	messageCmd.Flags().StringVar(&messageRedisURL, "redis-url", "", "Redis/Valkey URL (default: redis://localhost:6379)")
 	messageCmd.Flags().IntVar(&messageTimeout, "timeout", 30, "Timeout in seconds to wait for reply")
 	messageCmd.Flags().BoolVar(&messageUseAPI, "use-operator-api", true, "Use the operator API instead of direct Valkey connection")
 	messageCmd.Flags().StringVar(&messageOperatorURL, "operator-url", "", "Agent operator URL (default: auto-discover from current context)")
 }
+
+// inboxBacklog returns how many messages in an inbox the agent has not yet
+// read or acknowledged
+func inboxBacklog(ctx context.Context, rdb *redis.Client, stream string) (int64, error) {
+	groups, err := rdb.XInfoGroups(ctx, stream).Result()
+	if wire.IsMissingStream(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	info, err := rdb.XInfoStream(ctx, stream).Result()
+	if err != nil {
+		return 0, err
+	}
+	states := make([]wire.InboxGroupState, 0, len(groups))
+	for _, group := range groups {
+		states = append(states, wire.InboxGroupState{
+			Name:            group.Name,
+			Lag:             group.Lag,
+			Pending:         group.Pending,
+			LastDeliveredID: group.LastDeliveredID,
+			EntriesRead:     group.EntriesRead,
+		})
+	}
+	return wire.InboxBacklog(states, wire.InboxStreamState{
+		Length:          info.Length,
+		LastGeneratedID: info.LastGeneratedID,
+		EntriesAdded:    info.EntriesAdded,
+	}), nil
+}
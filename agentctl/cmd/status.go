@@ -76,6 +76,37 @@ var statusCmd = &cobra.Command{
 			fmt.Printf("Phase:    %s\n", phase)
 			fmt.Printf("Message:  %s\n", message)
 			fmt.Printf("Created:  %s\n", agent.GetCreationTimestamp().Time.Format("2006-01-02 15:04:05"))
+			if podName, _, _ := unstructured.NestedString(agent.Object, "status", "podName"); podName != "" {
+				fmt.Printf("Pod:      %s\n", podName)
+			}
+			if reason, _, _ := unstructured.NestedString(agent.Object, "status", "lastFailureReason"); reason != "" {
+				fmt.Printf("Last failure: %s\n", reason)
+			}
+			if next, _, _ := unstructured.NestedString(agent.Object, "status", "nextRestartTime"); next != "" {
+				fmt.Printf("Next restart: %s\n", next)
+			}
+			if exitCode, found, _ := unstructured.NestedInt64(agent.Object, "status", "exitCode"); found {
+				fmt.Printf("Exit code: %d\n", exitCode)
+			}
+			if msg, _, _ := unstructured.NestedString(agent.Object, "status", "terminationMessage"); msg != "" {
+				fmt.Printf("Termination message: %s\n", msg)
+			}
+
+			// Display the agent's conditions
+			conditions, _, _ := unstructured.NestedSlice(agent.Object, "status", "conditions")
+			if len(conditions) > 0 {
+				fmt.Println("\nConditions:")
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "  TYPE\tSTATUS\tREASON\tMESSAGE")
+				for _, c := range conditions {
+					cond, ok := c.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					fmt.Fprintf(w, "  %v\t%v\t%v\t%v\n", cond["type"], cond["status"], cond["reason"], cond["message"])
+				}
+				w.Flush()
+			}
 
 		} else {
 			// List agents from all agent-* namespaces
@@ -8,10 +8,13 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/Algoluna/agent-operator/pkg/wire"
 	"github.com/Algoluna/agentctl/pkg/config"
 	"gopkg.in/yaml.v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
@@ -131,6 +134,38 @@ func GetAgentTypeFromName(agentName string, kubeconfig string) (string, error) {
 	return "", fmt.Errorf("agent '%s' not found in any namespace", agentName)
 }
 
+// GetInboxLimits reads the inbox limits from an agent's spec.messaging
+func GetInboxLimits(agentName, agentType, kubeconfig string) (wire.InboxLimits, error) {
+	var limits wire.InboxLimits
+
+	k8sConfig := config.NewKubeConfig(kubeconfig, "microk8s")
+	restConfig, err := k8sConfig.GetClientConfig()
+	if err != nil {
+		return limits, err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return limits, fmt.Errorf("error creating client: %w", err)
+	}
+
+	agent, err := dynamicClient.Resource(schema.GroupVersionResource{
+		Group:    "agents.algoluna.com",
+		Version:  "v1alpha1",
+		Resource: "agents",
+	}).Namespace(GetNamespaceForAgent(agentType)).Get(context.Background(), agentName, metav1.GetOptions{})
+	if err != nil {
+		return limits, fmt.Errorf("error getting agent: %w", err)
+	}
+
+	limits.MaxLength, _, _ = unstructured.NestedInt64(agent.Object, "spec", "messaging", "maxInboxLength")
+	limits.TrimPolicy, _, _ = unstructured.NestedString(agent.Object, "spec", "messaging", "inboxTrimPolicy")
+	retention, _, _ := unstructured.NestedInt64(agent.Object, "spec", "messaging", "inboxRetentionSeconds")
+	limits.Retention = time.Duration(retention) * time.Second
+	limits.MaxBacklog, _, _ = unstructured.NestedInt64(agent.Object, "spec", "messaging", "maxBacklog")
+	return limits, nil
+}
+
 // ApplyRBACResources applies any RBAC resources found in the rbac/ directory
 func ApplyRBACResources(directory string, namespace string, kubeconfig string) error {
 	rbacDir := filepath.Join(directory, "rbac")